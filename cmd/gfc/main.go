@@ -41,7 +41,20 @@ func main() {
 			errors.Is(err, cli.ErrOutfileNotWritable),
 			errors.Is(err, cli.ErrBadInfileIsText),
 			errors.Is(err, cli.ErrBadOutfileDir),
-			errors.Is(err, cli.ErrInvalidModeAES):
+			errors.Is(err, cli.ErrInvalidModeAES),
+			errors.Is(err, cli.ErrInvalidModeRSA),
+			errors.Is(err, cli.ErrInvalidPayload),
+			errors.Is(err, cli.ErrInvalidKeyType),
+			errors.Is(err, cli.ErrKeyExists),
+			errors.Is(err, cli.ErrOutfileExists),
+			errors.Is(err, cli.ErrBadBatch),
+			errors.Is(err, cli.ErrInvalidPassphraseSource),
+			errors.Is(err, cli.ErrInvalidAAD),
+			errors.Is(err, cli.ErrInvalidMetadataFlag),
+			errors.Is(err, cli.ErrNoMetadata),
+			errors.Is(err, cli.ErrInvalidCompression),
+			errors.Is(err, cli.ErrInvalidEncoding),
+			errors.Is(err, cli.ErrInvalidKDF):
 
 			die(errUserError, err.Error())
 
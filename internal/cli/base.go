@@ -1,46 +1,134 @@
 package cli
 
 import (
+	"bytes"
+	"os"
+	"strconv"
 	"strings"
 
+	"github.com/pkg/errors"
+
 	"github.com/soyart/gfc/pkg/gfc"
 )
 
 // Hard-coded flag values for baseCryptFlags.EncodingFlag, used in baseCryptFlags.encoding()
 const (
-	b64lagValue     = "B64"
-	base64FlagValue = "BASE64"
-	hexFlagValue    = "HEX"
-	hFlagValue      = "H"
+	b64lagValue        = "B64"
+	base64FlagValue    = "BASE64"
+	b64URLFlagValue    = "B64URL"
+	base64URLFlagValue = "BASE64URL"
+	b64RawFlagValue    = "B64RAW"
+	base64RawFlagValue = "BASE64RAW"
+	b32FlagValue       = "B32"
+	base32FlagValue    = "BASE32"
+	b32RawFlagValue    = "B32RAW"
+	base32RawFlagValue = "BASE32RAW"
+	b58FlagValue       = "B58"
+	base58FlagValue    = "BASE58"
+	a85FlagValue       = "A85"
+	ascii85FlagValue   = "ASCII85"
+	z85FlagValue       = "Z85"
+	hexFlagValue       = "HEX"
+	hFlagValue         = "H"
+	armorFlagValue     = "ARMOR"
+	ascFlagValue       = "ASC"
+)
+
+// Hard-coded flag values for baseCommand.CompressionFlag, used in parseCompression().
+// noneFlagValue is also used for ioCommand.EncodingFlag.
+const (
+	noneFlagValue   = "NONE"
+	zstdFlagValue   = "ZSTD"
+	gzipFlagValue   = "GZIP"
+	s2FlagValue     = "S2"
+	snappyFlagValue = "SNAPPY"
+)
+
+// Hard-coded flag values for kdfCommand.KDFFlag, used in kdfCommand.kdf()
+const (
+	argon2idFlagValue = "ARGON2ID"
+	scryptFlagValue   = "SCRYPT"
+	pbkdf2FlagValue   = "PBKDF2"
+	sha256FlagValue   = "SHA256"
+	sha512FlagValue   = "SHA512"
 )
 
+// ioCommand represents the input and output flags shared by all subcommands,
+// including subcommands which do not encrypt, e.g. sign and verify.
+type ioCommand struct {
+	StdinText    bool   `arg:"-t,--text" default:"false" help:"Enter a text line manually to stdin"`
+	InfileFlag   string `arg:"-i,--infile" placeholder:"IN" help:"Input filename, or directory to encrypt as archive - stdin will be used if omitted"`
+	OutfileFlag  string `arg:"-o,--outfile" placeholder:"OUT" help:"Output filename, stdout will be used if omitted"`
+	ForceFlag    bool   `arg:"-f,--force" default:"false" help:"Overwrite existing output file"`
+	EncodingFlag string `arg:"-e,--encoding" placeholder:"ENC" help:"'hex', 'base64', 'base64url', 'base64raw', 'base32', 'base32raw', 'base58', 'ascii85', 'z85', 'armor', or 'none' encoding for input or output - decryption detects it if omitted"`
+}
+
 // baseCommand represents the shared gfc CLI flags between subcommands.
 // If you are adding a new algorithm, you don't have to use baseCommand,
 // just implement Command interface with any means.
 type baseCommand struct {
-	StdinText    bool   `arg:"-t,--text" default:"false" help:"Enter a text line manually to stdin"`
-	DecryptFlag  bool   `arg:"-d,--decrypt" default:"false" help:"Decrypt mode"`
-	InfileFlag   string `arg:"-i,--infile" placeholder:"IN" help:"Input filename, stdin will be used if omitted"`
-	OutfileFlag  string `arg:"-o,--outfile" placeholder:"OUT" help:"Output filename, stdout will be used if omitted"`
-	EncodingFlag string `arg:"-e,--encoding" placeholder:"ENC" help:"'base64' or 'hex' encoding for input or output"`
-	CompressFlag bool   `arg:"-c,--compress" default:"false" help:"Use ZSTD compression"`
+	DecryptFlag     bool     `arg:"-d,--decrypt" default:"false" help:"Decrypt mode"`
+	CompressFlag    bool     `arg:"-c,--zstd" default:"false" help:"Use ZSTD compression, same as --compress zstd"`
+	CompressionFlag string   `arg:"--compress" placeholder:"ALGO[:LEVEL]" help:"Compress before encryption with 'zstd' (level 1-22), 'gzip' (1-9), 's2' (1-3), 'snappy', or 'none' - decryption reads it from the input"`
+	Files           []string `arg:"positional" placeholder:"FILE" help:"Batch mode: encrypt each FILE (or glob) to FILE.gfc, or decrypt FILE.gfc to FILE - OUT is used as output directory"`
+	Jobs            int      `arg:"-j,--jobs" placeholder:"N" help:"Number of files processed concurrently in batch mode, defaults to number of CPUs"`
+
+	ioCommand
+	aadCommand
+	metadataCommand
+}
+
+// aadCommand represents the flags for additional authenticated data (AAD),
+// which binds ciphertext to context such as a filename or database row ID.
+type aadCommand struct {
+	AADFlag     string `arg:"--aad" placeholder:"AAD" help:"Bind ciphertext to associated data AAD, which must be given again to decrypt"`
+	AADFileFlag string `arg:"--aad-file" placeholder:"FILE" help:"Like --aad, but read associated data from FILE"`
+}
+
+// aad returns associated data from flags, or nil if none is given
+func (f *aadCommand) aad() ([]byte, error) {
+	switch {
+	case f.AADFlag != "" && f.AADFileFlag != "":
+		return nil, errors.Wrap(ErrInvalidAAD, "only one of --aad and --aad-file can be given")
+
+	case f.AADFileFlag != "":
+		aad, err := os.ReadFile(f.AADFileFlag)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read AAD file %s", f.AADFileFlag)
+		}
+
+		if len(aad) == 0 {
+			return nil, errors.Wrapf(ErrInvalidAAD, "empty AAD file %s", f.AADFileFlag)
+		}
+
+		return aad, nil
+
+	case f.AADFlag != "":
+		return []byte(f.AADFlag), nil
+	}
+
+	return nil, nil
 }
 
-func (f *baseCommand) filenameIn() string {
+func (f *ioCommand) filenameIn() string {
 	return f.InfileFlag
 }
 
-func (f *baseCommand) filenameOut() string {
+func (f *ioCommand) filenameOut() string {
 	return f.OutfileFlag
 }
 
 // Any struct that embeds *baseCryptFlags will inherit this
-func (f *baseCommand) stdinText() bool {
+func (f *ioCommand) stdinText() bool {
 	return f.StdinText
 }
 
+func (f *ioCommand) force() bool {
+	return f.ForceFlag
+}
+
 // Caller must call *os.File.Close() on their own
-func (f *baseCommand) outfile() string {
+func (f *ioCommand) outfile() string {
 	return f.OutfileFlag
 }
 
@@ -48,18 +136,208 @@ func (f *baseCommand) decrypt() bool {
 	return f.DecryptFlag
 }
 
-func (f *baseCommand) compression() bool {
-	return f.CompressFlag
+func (f *baseCommand) compression() (gfc.Compression, int, error) {
+	if f.CompressionFlag == "" {
+		if f.CompressFlag {
+			return gfc.CompressionZstd, 0, nil
+		}
+
+		return gfc.CompressionNone, 0, nil
+	}
+
+	compression, level, err := parseCompression(f.CompressionFlag)
+	if err != nil {
+		return gfc.CompressionNone, 0, err
+	}
+
+	if f.CompressFlag && compression != gfc.CompressionZstd {
+		return gfc.CompressionNone, 0, errors.Wrapf(ErrInvalidCompression, "-c is zstd, but --compress is %s", compression)
+	}
+
+	return compression, level, nil
+}
+
+func (f *baseCommand) detectCompression() bool {
+	return !f.CompressFlag && f.CompressionFlag == ""
+}
+
+// parseCompression parses compression flag value ALGO[:LEVEL].
+// Levels are checked by gfc when the compressor is created.
+func parseCompression(compression string) (gfc.Compression, int, error) {
+	name, levelStr, hasLevel := strings.Cut(compression, ":")
+
+	var c gfc.Compression
+	switch strings.ToUpper(name) {
+	case noneFlagValue:
+		c = gfc.CompressionNone
+
+	case zstdFlagValue:
+		c = gfc.CompressionZstd
+
+	case gzipFlagValue:
+		c = gfc.CompressionGzip
+
+	case s2FlagValue:
+		c = gfc.CompressionS2
+
+	case snappyFlagValue:
+		c = gfc.CompressionSnappy
+
+	default:
+		return gfc.CompressionNone, 0, errors.Wrapf(ErrInvalidCompression, "unknown compression %s", name)
+	}
+
+	if !hasLevel {
+		return c, 0, nil
+	}
+
+	level, err := strconv.Atoi(levelStr)
+	if err != nil || level < 1 {
+		return gfc.CompressionNone, 0, errors.Wrapf(ErrInvalidCompression, "bad level %s for %s", levelStr, c)
+	}
+
+	// Compress empty input to check level before reading input
+	if _, err := gfc.Compress(c, level, new(bytes.Buffer)); err != nil {
+		return gfc.CompressionNone, 0, errors.Wrap(ErrInvalidCompression, err.Error())
+	}
+
+	return c, level, nil
+}
+
+func (f *ioCommand) encoding() (gfc.Encoding, error) {
+	return parseEncoding(f.EncodingFlag)
+}
+
+func (f *ioCommand) detectEncoding() bool {
+	return f.EncodingFlag == ""
 }
 
-func (f *baseCommand) encoding() gfc.Encoding {
-	switch strings.ToUpper(f.EncodingFlag) {
+// parseEncoding parses encoding flag value, and returns gfc.EncodingNone if it is empty
+func parseEncoding(encoding string) (gfc.Encoding, error) {
+	switch strings.ToUpper(encoding) {
+	case "", noneFlagValue:
+		return gfc.EncodingNone, nil
+
 	case b64lagValue, base64FlagValue:
-		return gfc.EncodingBase64
+		return gfc.EncodingBase64, nil
+
+	case b64URLFlagValue, base64URLFlagValue:
+		return gfc.EncodingBase64URL, nil
+
+	case b64RawFlagValue, base64RawFlagValue:
+		return gfc.EncodingBase64Raw, nil
+
+	case b32FlagValue, base32FlagValue:
+		return gfc.EncodingBase32, nil
+
+	case b32RawFlagValue, base32RawFlagValue:
+		return gfc.EncodingBase32Raw, nil
+
+	case b58FlagValue, base58FlagValue:
+		return gfc.EncodingBase58, nil
+
+	case a85FlagValue, ascii85FlagValue:
+		return gfc.EncodingAscii85, nil
+
+	case z85FlagValue:
+		return gfc.EncodingZ85, nil
 
 	case hFlagValue, hexFlagValue:
-		return gfc.EncodingHex
+		return gfc.EncodingHex, nil
+
+	case armorFlagValue, ascFlagValue:
+		return gfc.EncodingArmor, nil
+	}
+
+	return gfc.EncodingNone, errors.Wrapf(ErrInvalidEncoding, "unknown encoding %s", encoding)
+}
+
+// parsePayload parses payload flag value of hybrid modes
+func parsePayload(payload string) (gfc.AlgoMode, error) {
+	switch strings.ToUpper(payload) {
+	case "GCM":
+		return gfc.ModeAesGCM, nil
+
+	case "XCC20":
+		return gfc.ModeXChaCha20Poly1305, nil
+	}
+
+	return gfc.ModeInvalid, errors.Wrapf(ErrInvalidPayload, "unknown payload mode %s", payload)
+}
+
+// kdfCommand represents the passphrase KDF flags shared by symmetric key subcommands.
+// The KDF flags only affect new encryption, since decryption uses the KDF recorded in the header.
+type kdfCommand struct {
+	KDFFlag           string `arg:"--kdf" default:"argon2id" placeholder:"KDF" help:"KDF for deriving key from passphrase: 'argon2id', 'scrypt', or 'pbkdf2'"`
+	KDFIterationsFlag uint32 `arg:"--kdf-iterations" placeholder:"N" help:"PBKDF2 iterations (KDF default if omitted)"`
+	KDFHashFlag       string `arg:"--kdf-hash" placeholder:"HASH" help:"PBKDF2 hash: 'sha256' or 'sha512' (default: sha256)"`
+	ScryptNFlag       uint32 `arg:"--scrypt-n" placeholder:"N" help:"scrypt CPU/memory cost, a power of 2 (KDF default if omitted)"`
+	ScryptRFlag       uint32 `arg:"--scrypt-r" placeholder:"R" help:"scrypt block size (KDF default if omitted)"`
+	ScryptPFlag       uint32 `arg:"--scrypt-p" placeholder:"P" help:"scrypt parallelization (KDF default if omitted)"`
+}
+
+func (f *kdfCommand) kdf() (gfc.KDFParams, error) {
+	scrypt := f.ScryptNFlag != 0 || f.ScryptRFlag != 0 || f.ScryptPFlag != 0
+	pbkdf2 := f.KDFIterationsFlag != 0 || f.KDFHashFlag != ""
+
+	var params gfc.KDFParams
+	switch kdf := strings.ToUpper(f.KDFFlag); {
+	case scrypt && kdf != scryptFlagValue:
+		return gfc.KDFParams{}, errors.Wrapf(ErrInvalidKDF, "--scrypt-n, --scrypt-r, and --scrypt-p are not supported for %s", f.KDFFlag)
+
+	case pbkdf2 && kdf != pbkdf2FlagValue:
+		return gfc.KDFParams{}, errors.Wrapf(ErrInvalidKDF, "--kdf-iterations and --kdf-hash are not supported for %s", f.KDFFlag)
+
+	case kdf == argon2idFlagValue:
+		params = gfc.DefaultKDFParams(gfc.KDFArgon2id)
+
+	case kdf == scryptFlagValue:
+		params = gfc.DefaultKDFParams(gfc.KDFScrypt)
+		if f.ScryptNFlag != 0 {
+			params.N = f.ScryptNFlag
+		}
+
+		if f.ScryptRFlag != 0 {
+			params.R = f.ScryptRFlag
+		}
+
+		if f.ScryptPFlag != 0 {
+			params.P = f.ScryptPFlag
+		}
+
+	case kdf == pbkdf2FlagValue:
+		params = gfc.DefaultKDFParams(gfc.KDFPBKDF2)
+		if f.KDFIterationsFlag != 0 {
+			params.Iterations = f.KDFIterationsFlag
+		}
+
+		hash, err := f.kdfHash()
+		if err != nil {
+			return gfc.KDFParams{}, err
+		}
+
+		params.Hash = hash
+
+	default:
+		return gfc.KDFParams{}, errors.Wrapf(ErrInvalidKDF, "unknown KDF %s", f.KDFFlag)
+	}
+
+	// Catch bad parameters before the passphrase is prompted for
+	if err := params.Validate(); err != nil {
+		return gfc.KDFParams{}, errors.Wrap(ErrInvalidKDF, err.Error())
+	}
+
+	return params, nil
+}
+
+func (f *kdfCommand) kdfHash() (gfc.KDFHash, error) {
+	switch strings.ToUpper(strings.ReplaceAll(f.KDFHashFlag, "-", "")) {
+	case "", sha256FlagValue:
+		return gfc.KDFHashSHA256, nil
+
+	case sha512FlagValue:
+		return gfc.KDFHashSHA512, nil
 	}
 
-	return gfc.EncodingNone
+	return 0, errors.Wrapf(ErrInvalidKDF, "unknown PBKDF2 hash %s", f.KDFHashFlag)
 }
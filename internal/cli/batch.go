@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/soyart/gfc/pkg/gfc"
+)
+
+// batchSuffix is appended to output filenames in batch encryption, and stripped in batch decryption
+const batchSuffix = ".gfc"
+
+type batchResult struct {
+	filenameIn  string
+	filenameOut string
+	err         error
+}
+
+func (f *baseCommand) batch() []string {
+	return f.Files
+}
+
+func (f *baseCommand) jobs() int {
+	if f.Jobs > 0 {
+		return f.Jobs
+	}
+
+	return runtime.NumCPU()
+}
+
+// runBatch encrypts or decrypts every file in batch on a pool of b.jobs() workers.
+// The key is read (or derived from passphrase) once, and shared by all files.
+// A summary is written to stderr, and ErrBatchFailed is returned if any file failed.
+func runBatch(g *Gfc, cmd command, b batcher, key []byte, opts []gfc.Option) error {
+	if cmd.stdinText() || len(cmd.filenameIn()) != 0 {
+		return errors.Wrap(ErrBadBatch, "batch files cannot be used with infile or text input")
+	}
+
+	// Batch output filenames are derived from input filenames
+	if m, ok := cmd.(metadater); ok && m.restoreName() {
+		return errors.Wrap(ErrBadBatch, "--restore-name cannot be used in batch mode")
+	}
+
+	outdir := cmd.filenameOut()
+	if len(outdir) != 0 && !isDir(outdir) {
+		return errors.Wrapf(ErrBadOutfileDir, "batch outfile %s is not a directory", outdir)
+	}
+
+	filenames, err := expandBatch(b.batch())
+	if err != nil {
+		return err
+	}
+
+	results := batchOutfiles(filenames, outdir, cmd.decrypt(), cmd.force())
+
+	// Share passphrase and derived keys between files,
+	// so that the user is prompted and the KDF is run only once.
+	// opts is copied, so that workers never share spare capacity of the caller's slice.
+	opts = append(opts[:len(opts):len(opts)], gfc.WithKeyCache(gfc.NewKeyCache()))
+
+	queue := make(chan *batchResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < b.jobs() && i < len(results); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for r := range queue {
+				r.err = g.runFile(cmd, key, opts, r.filenameIn, r.filenameOut)
+			}
+		}()
+	}
+
+	for i := range results {
+		if results[i].err == nil {
+			queue <- &results[i]
+		}
+	}
+
+	close(queue)
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "FAIL %s: %s\n", r.filenameIn, r.err.Error())
+
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "OK   %s -> %s\n", r.filenameIn, r.filenameOut)
+	}
+
+	fmt.Fprintf(os.Stderr, "%d succeeded, %d failed\n", len(results)-failed, failed)
+
+	if failed != 0 {
+		return errors.Wrapf(ErrBatchFailed, "%d of %d files failed", failed, len(results))
+	}
+
+	return nil
+}
+
+// expandBatch expands globs in patterns, and returns unique filenames in order.
+// Patterns without matches are kept as is, so that they fail as missing files.
+func expandBatch(patterns []string) ([]string, error) {
+	var filenames []string
+	seen := make(map[string]bool)
+
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(ErrBadBatch, "bad glob %s", pattern)
+		}
+
+		if len(matches) == 0 {
+			matches = []string{pattern}
+		}
+
+		for _, filename := range matches {
+			filename = filepath.Clean(filename)
+			if !seen[filename] {
+				seen[filename] = true
+				filenames = append(filenames, filename)
+			}
+		}
+	}
+
+	return filenames, nil
+}
+
+// batchOutfiles returns results with output filenames for filenames.
+// Output filename is input filename with batchSuffix appended when encrypting,
+// or stripped when decrypting, and is put in outdir if outdir is not empty.
+// Existing outfiles are never overwritten, unless force is true.
+func batchOutfiles(filenames []string, outdir string, decrypt bool, force bool) []batchResult {
+	results := make([]batchResult, len(filenames))
+	seen := make(map[string]bool)
+
+	for i, filename := range filenames {
+		r := &results[i]
+		r.filenameIn = filename
+		r.filenameOut = filename + batchSuffix
+
+		if decrypt {
+			if !strings.HasSuffix(filename, batchSuffix) || filename == batchSuffix {
+				r.err = errors.Wrapf(ErrBadBatch, "missing %s suffix", batchSuffix)
+				continue
+			}
+
+			r.filenameOut = strings.TrimSuffix(filename, batchSuffix)
+		}
+
+		if len(outdir) != 0 {
+			r.filenameOut = filepath.Join(outdir, filepath.Base(r.filenameOut))
+		}
+
+		if _, err := os.Lstat(r.filenameOut); (err == nil && !force) || seen[r.filenameOut] {
+			r.err = wrapErrFilename(ErrOutfileExists, r.filenameOut)
+			continue
+		}
+
+		seen[r.filenameOut] = true
+	}
+
+	return results
+}
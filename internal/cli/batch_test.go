@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexflint/go-arg"
+)
+
+func TestBatchOutfiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.log", "b.log", "b.log.gfc", "c.log.gfc"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filenames, err := expandBatch([]string{filepath.Join(dir, "*.log"), filepath.Join(dir, "a.log")})
+	if err != nil {
+		t.Fatalf("failed to expand batch: %s", err.Error())
+	}
+
+	if len(filenames) != 2 {
+		t.Fatalf("expecting 2 unique files, got %v", filenames)
+	}
+
+	results := batchOutfiles(filenames, "", false, false)
+	if results[0].err != nil || results[0].filenameOut != filepath.Join(dir, "a.log.gfc") {
+		t.Fatalf("unexpected result for a.log: %+v", results[0])
+	}
+
+	// b.log.gfc already exists
+	if !errors.Is(results[1].err, ErrOutfileExists) {
+		t.Fatalf("expecting ErrOutfileExists for b.log, got %v", results[1].err)
+	}
+
+	outdir := t.TempDir()
+	results = batchOutfiles([]string{filepath.Join(dir, "c.log.gfc"), filepath.Join(dir, "a.log")}, outdir, true, false)
+	if results[0].err != nil || results[0].filenameOut != filepath.Join(outdir, "c.log") {
+		t.Fatalf("unexpected result for c.log.gfc: %+v", results[0])
+	}
+
+	if !errors.Is(results[1].err, ErrBadBatch) {
+		t.Fatalf("expecting ErrBadBatch for a.log, got %v", results[1].err)
+	}
+}
+
+// TestBatchRoundTrip runs batch encryption and decryption with many workers,
+// and should be run with -race to detect workers sharing options.
+func TestBatchRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	keyfile := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyfile, bytes.Repeat([]byte{0x42}, 32), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var plaintexts, ciphertexts []string
+	for i := 0; i < 16; i++ {
+		filename := filepath.Join(dir, fmt.Sprintf("%d.log", i))
+		if err := os.WriteFile(filename, []byte(filename), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		plaintexts = append(plaintexts, filename)
+		ciphertexts = append(ciphertexts, filename+batchSuffix)
+	}
+
+	outdir := t.TempDir()
+
+	for _, args := range [][]string{
+		append([]string{"aes", "-k", keyfile, "-j", "8"}, plaintexts...),
+		append([]string{"aes", "-d", "-k", keyfile, "-j", "8", "-o", outdir}, ciphertexts...),
+	} {
+		g := new(Gfc)
+
+		p, err := arg.NewParser(arg.Config{}, g)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := p.Parse(args); err != nil {
+			t.Fatalf("failed to parse args %v: %s", args, err.Error())
+		}
+
+		if err := g.Run(); err != nil {
+			t.Fatalf("failed to run %v: %s", args, err.Error())
+		}
+	}
+
+	for _, filename := range plaintexts {
+		b, err := os.ReadFile(filepath.Join(outdir, filepath.Base(filename)))
+		if err != nil {
+			t.Fatalf("failed to read decrypted %s: %s", filename, err.Error())
+		}
+
+		if string(b) != filename {
+			t.Fatalf("unexpected plaintext for %s: %q", filename, b)
+		}
+	}
+}
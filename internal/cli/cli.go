@@ -11,23 +11,81 @@ type Gfc struct {
 	CommandAES      *cmdAES      `arg:"subcommand:aes" help:"Use gfc-aes for AES encryption: see 'gfc aes --help'"`
 	CommandRSA      *cmdRSA      `arg:"subcommand:rsa" help:"Use gfc-rsa for RSA encryption: see 'gfc rsa --help'"`
 	CommandChaCha20 *cmdChaCha20 `arg:"subcommand:cc20" help:"Use gfc-cc20 for ChaCha20/XChaCha20-Poly1305 encryption: see 'gfc cc20 --help'"`
+	CommandX25519   *cmdX25519   `arg:"subcommand:x25519" help:"Use gfc-x25519 for X25519 public-key encryption: see 'gfc x25519 --help'"`
+	CommandMulti    *cmdMulti    `arg:"subcommand:multi" help:"Use gfc-multi for multi-recipient encryption: see 'gfc multi --help'"`
+	CommandSign     *cmdSign     `arg:"subcommand:sign" help:"Use gfc-sign for Ed25519 signatures: see 'gfc sign --help'"`
+	CommandVerify   *cmdVerify   `arg:"subcommand:verify" help:"Use gfc-verify to verify Ed25519 signatures: see 'gfc verify --help'"`
+	CommandKeygen   *cmdKeygen   `arg:"subcommand:keygen" help:"Use gfc-keygen to generate keys: see 'gfc keygen --help'"`
 }
 
 type subcommand interface {
-	decrypt() bool                   // decrypt returns if user specified decryption operation
-	filenameIn() string              // filenameIn returns input filename
-	filenameOut() string             // filenameOut returns output filename
-	stdinText() bool                 // stdinText returns whether this run takes text input from stdin
-	compression() bool               // compression checks if user wants to include ZSTD in the pipeline
-	algoMode() (gfc.AlgoMode, error) // algoMode  checks if user specified invalid mode before attempting to read file
-	encoding() gfc.Encoding          // encoding returns if user wants to apply encoding to the pipeline, and if so, which one
+	decrypt() bool                              // decrypt returns if user specified decryption operation
+	filenameIn() string                         // filenameIn returns input filename
+	filenameOut() string                        // filenameOut returns output filename
+	stdinText() bool                            // stdinText returns whether this run takes text input from stdin
+	force() bool                                // force returns if existing output file should be overwritten
+	compression() (gfc.Compression, int, error) // compression returns compression and level applied to plaintext before encryption
+	algoMode() (gfc.AlgoMode, error)            // algoMode  checks if user specified invalid mode before attempting to read file
+	encoding() (gfc.Encoding, error)            // encoding returns if user wants to apply encoding to the pipeline, and if so, which one
+	detectEncoding() bool                       // detectEncoding returns if encoding of decryption input should be detected, i.e. no encoding flag is given
+	detectCompression() bool                    // detectCompression returns if compression of decrypted output should be read from the header, i.e. no compression flag is given
+}
+
+// kdfer is implemented by commands which can derive key from passphrase
+type kdfer interface {
+	kdf() (gfc.KDFParams, error) // kdf returns the KDF used to derive key from passphrase for new encryption
+}
+
+// payloader is implemented by commands which encrypt payload with a file key (hybrid modes)
+type payloader interface {
+	payload() (gfc.AlgoMode, error) // payload returns the AEAD mode used to encrypt the payload
+}
+
+// passphraser is implemented by commands which can read passphrase non-interactively
+type passphraser interface {
+	readPassphrase() ([]byte, error) // readPassphrase returns passphrase, or nil if the user should be prompted
+	passphraseStdin() bool           // passphraseStdin returns if passphrase is read from stdin
+}
+
+// aader is implemented by commands which can bind ciphertext to associated data
+type aader interface {
+	aad() ([]byte, error) // aad returns associated data, or nil if none is given
+}
+
+// metadater is implemented by commands which can encrypt and restore metadata of the original file
+type metadater interface {
+	metadata() bool    // metadata returns if metadata of input file should be encrypted with the output
+	restoreName() bool // restoreName returns if decrypted output should be written to the original filename
+	preserve() bool    // preserve returns if original mode and mtime should be restored to decrypted output
+}
+
+// optioner is implemented by commands which need extra gfc options, e.g. recipients
+type optioner interface {
+	options() ([]gfc.Option, error) // options returns extra gfc options for this run
+}
+
+// keygener is implemented by commands which can generate their own keys with gfc keygen
+type keygener interface {
+	keygen() bool    // keygen returns if user wants to generate new key instead
+	keyType() string // keyType returns the gfc keygen key type of this command
+}
+
+// batcher is implemented by commands which can process many files in one run
+type batcher interface {
+	batch() []string // batch returns paths or globs of files to process, if any
+	jobs() int       // jobs returns the number of files processed concurrently
+}
+
+// streamer is implemented by commands which can process file input as chunked stream
+type streamer interface {
+	stream() bool // stream returns if this run should be processed as stream
 }
 
 type command interface {
 	subcommand
 
 	key() ([]byte, error)
-	crypt(mode gfc.AlgoMode, buf gfc.Buffer, key []byte, decrypt bool) (gfc.Buffer, error)
+	crypt(mode gfc.AlgoMode, buf gfc.Buffer, key []byte, decrypt bool, opts ...gfc.Option) (gfc.Buffer, error)
 }
 
 // Run is the application code for gfc.
@@ -43,50 +101,192 @@ func (g *Gfc) Run() error {
 	case g.CommandChaCha20 != nil:
 		cmd = g.CommandChaCha20
 
+	case g.CommandX25519 != nil:
+		cmd = g.CommandX25519
+
+	case g.CommandMulti != nil:
+		cmd = g.CommandMulti
+
+	// sign, verify, and keygen do not encrypt, so they are not commands
+	case g.CommandSign != nil:
+		return g.CommandSign.run()
+
+	case g.CommandVerify != nil:
+		return g.CommandVerify.run()
+
+	case g.CommandKeygen != nil:
+		return g.CommandKeygen.run()
+
 	default:
 		return ErrMissingSubcommand
 	}
 
-	// Check bad mode before open files
+	// Check bad mode and compression before open files
 	_, err := cmd.algoMode()
 	if err != nil {
 		return errors.Wrap(err, "invalid algorithm mode")
 	}
 
+	if _, _, err := cmd.compression(); err != nil {
+		return errors.Wrap(err, "bad compression flag")
+	}
+
+	if _, err := cmd.encoding(); err != nil {
+		return errors.Wrap(err, "bad encoding flag")
+	}
+
+	if k, ok := cmd.(keygener); ok && k.keygen() {
+		return runKeygen(k, cmd.filenameOut())
+	}
+
+	opts, err := cryptOptions(cmd)
+	if err != nil {
+		return err
+	}
+
 	key, err := cmd.key()
 	if err != nil {
 		return errors.Wrapf(err, "failed to read key")
 	}
 
-	infile, err := openInput(cmd.filenameIn(), cmd.stdinText())
+	if b, ok := cmd.(batcher); ok && len(b.batch()) != 0 {
+		return runBatch(g, cmd, b, key, opts)
+	}
+
+	return g.runFile(cmd, key, opts, cmd.filenameIn(), cmd.filenameOut())
+}
+
+// runFile encrypts or decrypts filenameIn to filenameOut with cmd
+func (g *Gfc) runFile(
+	cmd command,
+	key []byte,
+	opts []gfc.Option,
+	filenameIn string,
+	filenameOut string,
+) error {
+	infile, archive, err := openCryptInput(cmd, filenameIn)
 	if err != nil {
 		return err
 	}
 
 	defer infile.Close()
 
-	outfile, err := openOutput(cmd.filenameOut())
+	// Directory input is archived, and the archive flag is recorded in the header,
+	// so that decryption knows to extract the output to a directory.
+	// opts may be shared by concurrent runs (see batch.go), so it is copied before appending.
+	if archive {
+		opts = append(opts[:len(opts):len(opts)], gfc.WithArchive(true))
+	}
+
+	opts, rs, err := metadataOptions(cmd, filenameIn, filenameOut, opts)
 	if err != nil {
 		return err
 	}
 
-	defer outfile.Close()
+	if useStream(cmd, filenameIn) {
+		mode, _ := cmd.algoMode()
+		if err := runStream(cmd, mode, key, infile, filenameOut, opts, rs); err != nil {
+			return errors.Wrap(err, "cli.Gfc: stream returned error")
+		}
+
+		return nil
+	}
 
 	buf, err := readInput(infile, cmd.stdinText())
 	if err != nil {
 		return errors.Wrap(err, "failed to read input")
 	}
 
-	buf, err = g.core(cmd, buf, key)
+	buf, hdr, err := g.core(cmd, buf, key, opts)
 	if err != nil {
 		return errors.Wrap(err, "cli.Gfc: core returned error")
 	}
 
-	if _, err := buf.WriteTo(outfile); err != nil {
-		return errors.Wrapf(err, "failed to write to outfile %s", outfile.Name())
+	return writeOutput(buf, filenameOut, cmd.force(), hdr, rs)
+}
+
+// isArchive reports whether hdr records a directory archive plaintext
+func isArchive(hdr *gfc.Header) bool {
+	return hdr != nil && hdr.Flags&gfc.FlagArchive != 0
+}
+
+// runKeygen generates new key for k with gfc keygen, so that --keygen writes the same key files
+func runKeygen(k keygener, filenameOut string) error {
+	return (&cmdKeygen{KeyType: k.keyType(), OutfileFlag: filenameOut}).run()
+}
+
+// cryptOptions returns gfc options for cmd
+func cryptOptions(cmd command) ([]gfc.Option, error) {
+	compression, _, err := cmd.compression()
+	if err != nil {
+		return nil, errors.Wrap(err, "bad compression flag")
+	}
+
+	encoding, err := cmd.encoding()
+	if err != nil {
+		return nil, errors.Wrap(err, "bad encoding flag")
+	}
+
+	opts := []gfc.Option{
+		gfc.WithCompression(compression),
+		gfc.WithEncoding(encoding),
+	}
+
+	if k, ok := cmd.(kdfer); ok {
+		kdf, err := k.kdf()
+		if err != nil {
+			return nil, errors.Wrap(err, "bad KDF flag")
+		}
+
+		opts = append(opts, gfc.WithKDF(kdf))
+	}
+
+	if p, ok := cmd.(payloader); ok {
+		payload, err := p.payload()
+		if err != nil {
+			return nil, errors.Wrap(err, "bad payload flag")
+		}
+
+		opts = append(opts, gfc.WithPayload(payload))
+	}
+
+	if p, ok := cmd.(passphraser); ok {
+		// Passphrase read from stdin would consume, and close, input from stdin
+		if p.passphraseStdin() && readsStdin(cmd) {
+			return nil, errors.Wrap(ErrInvalidPassphraseSource, "--passphrase-fd 0 cannot be used with input from stdin")
+		}
+
+		passphrase, err := p.readPassphrase()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read passphrase")
+		}
+
+		if passphrase != nil {
+			opts = append(opts, gfc.WithPassphrase(passphrase))
+		}
+	}
+
+	if a, ok := cmd.(aader); ok {
+		aad, err := a.aad()
+		if err != nil {
+			return nil, err
+		}
+
+		if aad != nil {
+			opts = append(opts, gfc.WithAAD(aad))
+		}
+	}
+
+	if o, ok := cmd.(optioner); ok {
+		extra, err := o.options()
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, extra...)
 	}
 
-	return nil
+	return opts, nil
 }
 
 //nolint:wrapcheck
@@ -94,7 +294,8 @@ func preProcess(
 	buf gfc.Buffer,
 	decrypt bool,
 	encoding gfc.Encoding,
-	compress bool,
+	compression gfc.Compression,
+	level int,
 ) (
 	gfc.Buffer,
 	error,
@@ -103,7 +304,7 @@ func preProcess(
 		return gfc.Decode(encoding, buf)
 	}
 
-	return gfc.Compress(compress, buf)
+	return gfc.Compress(compression, level, buf)
 }
 
 //nolint:wrapcheck
@@ -111,50 +312,77 @@ func postProcess(
 	buf gfc.Buffer,
 	decrypt bool,
 	encoding gfc.Encoding,
-	compress bool,
+	compression gfc.Compression,
 ) (
 	gfc.Buffer,
 	error,
 ) {
 	if decrypt {
-		return gfc.Decompress(compress, buf)
+		return gfc.Decompress(compression, buf)
 	}
 
 	return gfc.Encode(encoding, buf)
 }
 
 // core pre-processes, encrypts/decrypts, and post-processes buf.
+// When decrypting, it also returns the header of the input, or nil if it has none.
 func (g *Gfc) core(
 	cmd command,
 	buf gfc.Buffer,
 	key []byte,
+	opts []gfc.Option,
 ) (
 	gfc.Buffer,
+	*gfc.Header,
 	error,
 ) {
 	mode, err := cmd.algoMode()
 	if err != nil {
-		panic("unexpected invalid mode")
+		return nil, nil, errors.Wrap(err, "invalid algorithm mode")
+	}
+
+	compression, level, err := cmd.compression()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "bad compression flag")
+	}
+
+	encoding, err := cmd.encoding()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "bad encoding flag")
 	}
 
 	decrypt := cmd.decrypt()
-	encoding := cmd.encoding()
-	compress := cmd.compression()
+	if decrypt && cmd.detectEncoding() {
+		encoding = gfc.DetectEncoding(buf.Bytes())
+	}
 
-	buf, err = preProcess(buf, decrypt, encoding, compress)
+	buf, err = preProcess(buf, decrypt, encoding, compression, level)
 	if err != nil {
-		return nil, errors.Wrap(err, "input preprocessing failed")
+		return nil, nil, errors.Wrap(err, "input preprocessing failed")
+	}
+
+	// gfc output records its mode and compression in the header, so the header
+	// takes precedence over the mode flag, and is used if no compression flag is given.
+	// Output without header (older gfc) is only decompressed with compression flags.
+	var hdr *gfc.Header
+	if decrypt {
+		if hdr, err = gfc.ParseHeader(buf.Bytes()); err == nil {
+			mode = hdr.Mode
+			if cmd.detectCompression() {
+				compression = hdr.Compression
+			}
+		}
 	}
 
-	buf, err = cmd.crypt(mode, buf, key, decrypt)
+	buf, err = cmd.crypt(mode, buf, key, decrypt, opts...)
 	if err != nil {
-		return nil, errors.Wrap(err, "cryptography error")
+		return nil, nil, errors.Wrap(err, "cryptography error")
 	}
 
-	buf, err = postProcess(buf, decrypt, encoding, compress)
+	buf, err = postProcess(buf, decrypt, encoding, compression)
 	if err != nil {
-		return nil, errors.Wrap(err, "output processing failed")
+		return nil, nil, errors.Wrap(err, "output processing failed")
 	}
 
-	return buf, nil
+	return buf, hdr, nil
 }
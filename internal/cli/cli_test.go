@@ -1,7 +1,116 @@
 package cli
 
-import "testing"
+import (
+	"errors"
+	"testing"
+
+	"github.com/soyart/gfc/pkg/gfc"
+)
 
 func TestCliCore(t *testing.T) {
 	// TODO: write test for Gfc.core
 }
+
+func TestParseCompression(t *testing.T) {
+	tests := map[string]struct {
+		compression gfc.Compression
+		level       int
+	}{
+		"none":    {gfc.CompressionNone, 0},
+		"zstd":    {gfc.CompressionZstd, 0},
+		"ZSTD:19": {gfc.CompressionZstd, 19},
+		"gzip:9":  {gfc.CompressionGzip, 9},
+		"s2:3":    {gfc.CompressionS2, 3},
+		"snappy":  {gfc.CompressionSnappy, 0},
+	}
+
+	for flag, expected := range tests {
+		compression, level, err := parseCompression(flag)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", flag, err.Error())
+		}
+
+		if compression != expected.compression || level != expected.level {
+			t.Fatalf("%s: expecting %s:%d, got %s:%d", flag, expected.compression, expected.level, compression, level)
+		}
+	}
+
+	for _, flag := range []string{"lzma", "zstd:", "zstd:0", "zstd:23", "gzip:x", "snappy:1", "none:1"} {
+		if _, _, err := parseCompression(flag); !errors.Is(err, ErrInvalidCompression) {
+			t.Fatalf("%s: expecting ErrInvalidCompression, got %v", flag, err)
+		}
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	tests := map[string]gfc.Encoding{
+		"":          gfc.EncodingNone,
+		"none":      gfc.EncodingNone,
+		"b64":       gfc.EncodingBase64,
+		"base64url": gfc.EncodingBase64URL,
+		"B64RAW":    gfc.EncodingBase64Raw,
+		"base32":    gfc.EncodingBase32,
+		"b32raw":    gfc.EncodingBase32Raw,
+		"base58":    gfc.EncodingBase58,
+		"ascii85":   gfc.EncodingAscii85,
+		"z85":       gfc.EncodingZ85,
+		"hex":       gfc.EncodingHex,
+		"armor":     gfc.EncodingArmor,
+	}
+
+	for flag, expected := range tests {
+		encoding, err := parseEncoding(flag)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", flag, err.Error())
+		}
+
+		if encoding != expected {
+			t.Fatalf("%s: expecting encoding %d, got %d", flag, expected, encoding)
+		}
+	}
+
+	for _, flag := range []string{"base", "base16", "uu"} {
+		if _, err := parseEncoding(flag); !errors.Is(err, ErrInvalidEncoding) {
+			t.Fatalf("%s: expecting ErrInvalidEncoding, got %v", flag, err)
+		}
+	}
+}
+
+func TestKDF(t *testing.T) {
+	params, err := (&kdfCommand{KDFFlag: "scrypt", ScryptNFlag: 1 << 16, ScryptPFlag: 2}).kdf()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if params.KDF != gfc.KDFScrypt || params.N != 1<<16 || params.R != gfc.DefaultKDFParams(gfc.KDFScrypt).R || params.P != 2 {
+		t.Fatalf("unexpected scrypt parameters %+v", params)
+	}
+
+	params, err = (&kdfCommand{KDFFlag: "pbkdf2", KDFIterationsFlag: 600000}).kdf()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if params.KDF != gfc.KDFPBKDF2 || params.Iterations != 600000 || params.Hash != gfc.KDFHashSHA256 {
+		t.Fatalf("unexpected PBKDF2 parameters %+v", params)
+	}
+
+	bad := map[string]kdfCommand{
+		"scrypt N not power of 2":    {KDFFlag: "scrypt", ScryptNFlag: 1000},
+		"scrypt memory too large":    {KDFFlag: "scrypt", ScryptNFlag: 1 << 30, ScryptRFlag: 8},
+		"scrypt with iterations":     {KDFFlag: "scrypt", KDFIterationsFlag: 10},
+		"scrypt N with argon2id KDF": {KDFFlag: "argon2id", ScryptNFlag: 1 << 16},
+		"scrypt r with pbkdf2 KDF":   {KDFFlag: "pbkdf2", ScryptRFlag: 8},
+		"iterations with argon2id":   {KDFFlag: "argon2id", KDFIterationsFlag: 600000},
+		"hash with argon2id":         {KDFFlag: "argon2id", KDFHashFlag: "sha512"},
+		"hash with scrypt":           {KDFFlag: "scrypt", KDFHashFlag: "sha256"},
+		"too many iterations":        {KDFFlag: "pbkdf2", KDFIterationsFlag: 1 << 30},
+		"unknown hash":               {KDFFlag: "pbkdf2", KDFHashFlag: "md5"},
+	}
+
+	for name, flags := range bad {
+		if _, err := flags.kdf(); !errors.Is(err, ErrInvalidKDF) {
+			t.Fatalf("%s: expecting ErrInvalidKDF, got %v", name, err)
+		}
+	}
+}
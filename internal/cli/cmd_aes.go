@@ -1,8 +1,6 @@
 package cli
 
 import (
-	"fmt"
-	"os"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -11,10 +9,14 @@ import (
 )
 
 type cmdAES struct {
-	AesMode string `arg:"-m,--mode" default:"GCM" placeholder:"MODE" help:"AES mode"`
-	Keyfile string `arg:"-k,--key,env:KEY" placeholder:"KEY" help:"256-bit keyfile for AES"`
+	AesMode   string `arg:"-m,--mode" default:"GCM" placeholder:"MODE" help:"AES mode"`
+	Keyfile   string `arg:"-k,--key,env:KEY" placeholder:"KEY" help:"256-bit keyfile for AES"`
+	NoStream  bool   `arg:"--no-stream" default:"false" help:"Encrypt file input in one shot instead of chunked stream"`
+	LegacyCTR bool   `arg:"--legacy-ctr" default:"false" help:"Allow decrypting unauthenticated AES256-CTR output from older gfc (tampering is NOT detected)"`
 
 	baseCommand
+	kdfCommand
+	passphraseCommand
 }
 
 func (c *cmdAES) algoMode() (gfc.AlgoMode, error) {
@@ -30,12 +32,23 @@ func (c *cmdAES) algoMode() (gfc.AlgoMode, error) {
 	return gfc.ModeInvalid, errors.Wrapf(ErrInvalidModeAES, "unknown mode %s", c.AesMode)
 }
 
+// Only GCM is encrypted as chunked stream, while any gfc output can be decrypted from stream
+func (c *cmdAES) stream() bool {
+	mode, err := c.algoMode()
+
+	return !c.NoStream && err == nil && (c.DecryptFlag || mode == gfc.ModeAesGCM)
+}
+
+func (c *cmdAES) options() ([]gfc.Option, error) {
+	return []gfc.Option{gfc.WithLegacyCTR(c.LegacyCTR)}, nil
+}
+
 func (c *cmdAES) key() ([]byte, error) {
 	if len(c.Keyfile) == 0 {
 		return nil, nil
 	}
 
-	return os.ReadFile(c.Keyfile)
+	return readKeyfile(c.Keyfile)
 }
 
 func (c *cmdAES) crypt(
@@ -43,6 +56,7 @@ func (c *cmdAES) crypt(
 	buf gfc.Buffer,
 	key []byte,
 	decrypt bool,
+	opts ...gfc.Option,
 ) (
 	gfc.Buffer,
 	error,
@@ -50,18 +64,22 @@ func (c *cmdAES) crypt(
 	switch mode {
 	case gfc.ModeAesGCM:
 		if decrypt {
-			return gfc.DecryptGCM(buf, key)
+			return gfc.DecryptGCM(buf, key, opts...)
 		}
 
-		return gfc.EncryptGCM(buf, key)
+		return gfc.EncryptGCM(buf, key, opts...)
 
-	case gfc.ModeAesCTR:
+	case gfc.ModeAesCTR, gfc.ModeAesCTRLegacy:
 		if decrypt {
-			return gfc.DecryptCTR(buf, key)
+			if c.LegacyCTR {
+				return gfc.DecryptLegacyCTR(buf, key, opts...)
+			}
+
+			return gfc.DecryptCTR(buf, key, opts...)
 		}
 
-		return gfc.EncryptCTR(buf, key)
+		return gfc.EncryptCTR(buf, key, opts...)
 	}
 
-	return nil, fmt.Errorf("invalid AES mode %d", mode)
+	return nil, errors.Wrapf(gfc.ErrInvalidMode, "invalid AES mode %s", mode)
 }
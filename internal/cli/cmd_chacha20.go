@@ -1,18 +1,21 @@
 package cli
 
 import (
-	"fmt"
-	"os"
 	"strings"
 
+	"github.com/pkg/errors"
+
 	"github.com/soyart/gfc/pkg/gfc"
 )
 
 type cmdChaCha20 struct {
 	ChaCha20Mode string `arg:"-m, --mode" placeholder:"[cc20 | xcc20]" default:"xcc20" help:"Supply any string containing 'x' for XChaCha20-Poly1305, and any string without 'x' for ChaCha20-Poly1305"`
 	Keyfile      string `arg:"-k,--key,env:KEY" placeholder:"KEY" help:"256-bit Keyfile for AES"`
+	NoStream     bool   `arg:"--no-stream" default:"false" help:"Encrypt file input in one shot instead of chunked stream"`
 
 	baseCommand
+	kdfCommand
+	passphraseCommand
 }
 
 // Only XChaCha20-Poly1305 is supported for family of ChaCha20 ciphers
@@ -24,12 +27,16 @@ func (c *cmdChaCha20) algoMode() (gfc.AlgoMode, error) {
 	return gfc.ModeChaCha20Poly1305, nil
 }
 
+func (c *cmdChaCha20) stream() bool {
+	return !c.NoStream
+}
+
 func (c *cmdChaCha20) key() ([]byte, error) {
 	if len(c.Keyfile) == 0 {
 		return nil, nil
 	}
 
-	return os.ReadFile(c.Keyfile)
+	return readKeyfile(c.Keyfile)
 }
 
 func (c *cmdChaCha20) crypt(
@@ -37,6 +44,7 @@ func (c *cmdChaCha20) crypt(
 	buf gfc.Buffer,
 	key []byte,
 	decrypt bool,
+	opts ...gfc.Option,
 ) (
 	gfc.Buffer,
 	error,
@@ -44,18 +52,18 @@ func (c *cmdChaCha20) crypt(
 	switch mode {
 	case gfc.ModeXChaCha20Poly1305:
 		if decrypt {
-			return gfc.DecryptXChaCha20Poly1305(buf, key)
+			return gfc.DecryptXChaCha20Poly1305(buf, key, opts...)
 		}
 
-		return gfc.EncryptXChaCha20Poly1305(buf, key)
+		return gfc.EncryptXChaCha20Poly1305(buf, key, opts...)
 
 	case gfc.ModeChaCha20Poly1305:
 		if decrypt {
-			return gfc.DecryptChaCha20Poly1305(buf, key)
+			return gfc.DecryptChaCha20Poly1305(buf, key, opts...)
 		}
 
-		return gfc.EncryptChaCha20Poly1305(buf, key)
+		return gfc.EncryptChaCha20Poly1305(buf, key, opts...)
 	}
 
-	return nil, fmt.Errorf("invalid ChaCha20 mode %d (should not happen)", mode)
+	return nil, errors.Wrapf(gfc.ErrInvalidMode, "invalid ChaCha20 mode %s", mode)
 }
@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/soyart/gfc/pkg/gfc"
+)
+
+// Hard-coded flag values for cmdKeygen.KeyType
+const (
+	symmetricKeyTypeValue = "SYMMETRIC"
+	aesKeyTypeValue       = "AES"
+	rsaKeyTypeValue       = "RSA"
+	x25519KeyTypeValue    = "X25519"
+	ed25519KeyTypeValue   = "ED25519"
+)
+
+// pubKeySuffix is appended to outfile name for public key file
+const pubKeySuffix = ".pub"
+
+type cmdKeygen struct {
+	KeyType       string `arg:"positional,required" placeholder:"TYPE" help:"Key type: 'symmetric' (256-bit keyfile for aes and cc20), 'rsa', 'x25519', or 'ed25519'"`
+	OutfileFlag   string `arg:"-o,--outfile" placeholder:"OUT" help:"Output filename for symmetric or private key, with public key written to OUT.pub - stdout will be used if omitted"`
+	Bits          int    `arg:"-b,--bits" default:"4096" placeholder:"BITS" help:"RSA key size"`
+	PrivateFormat string `arg:"--private-format" default:"pkcs1" placeholder:"[pkcs1 | pkcs8]" help:"RSA private key PEM format"`
+	PublicFormat  string `arg:"--public-format" default:"pkix" placeholder:"[pkix | pkcs1]" help:"RSA public key PEM format"`
+	EncodingFlag  string `arg:"-e,--encoding" placeholder:"ENC" help:"'base64' or 'hex' encoding for symmetric key"`
+}
+
+// parseKeyFormat parses RSA key format flag value
+func parseKeyFormat(format string) (gfc.KeyFormat, error) {
+	switch strings.ToUpper(format) {
+	case "PKCS1":
+		return gfc.KeyFormatPKCS1, nil
+
+	case "PKCS8":
+		return gfc.KeyFormatPKCS8, nil
+
+	case "PKIX":
+		return gfc.KeyFormatPKIX, nil
+	}
+
+	return 0, errors.Wrapf(ErrInvalidKeyType, "unknown key format %s", format)
+}
+
+// generate returns new private (or symmetric) key, and public key if any
+func (c *cmdKeygen) generate() ([]byte, []byte, error) {
+	keyType := strings.ToUpper(c.KeyType)
+	if c.EncodingFlag != "" && keyType != symmetricKeyTypeValue && keyType != aesKeyTypeValue {
+		return nil, nil, errors.Wrap(ErrInvalidKeyType, "encoding is only supported for symmetric keys")
+	}
+
+	encoding, err := parseEncoding(c.EncodingFlag)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Keyfiles are only read raw, or hex or base64-encoded (see gfc.DecodeKeyfile)
+	switch encoding {
+	case gfc.EncodingNone, gfc.EncodingHex, gfc.EncodingBase64:
+	default:
+		return nil, nil, errors.Wrapf(ErrInvalidEncoding, "%s is not supported for symmetric keys", c.EncodingFlag)
+	}
+
+	switch keyType {
+	case symmetricKeyTypeValue, aesKeyTypeValue:
+		key, err := gfc.GenerateKey()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		encoded, err := gfc.Encode(encoding, bytes.NewBuffer(key))
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to encode key")
+		}
+
+		return encoded.Bytes(), nil, nil
+
+	case rsaKeyTypeValue:
+		priFormat, err := parseKeyFormat(c.PrivateFormat)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pubFormat, err := parseKeyFormat(c.PublicFormat)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pub, pri, err := gfc.GenerateRSA(c.Bits, priFormat, pubFormat)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return pri, pub, nil
+
+	case x25519KeyTypeValue:
+		pub, pri, err := gfc.GenerateX25519()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return []byte(fmt.Sprintf("# public key: %s\n%s\n", pub, pri)), []byte(pub + "\n"), nil
+
+	case ed25519KeyTypeValue:
+		pub, pri, err := gfc.GenerateEd25519()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return []byte(fmt.Sprintf("# public key: %s\n%s\n", pub, pri)), []byte(pub + "\n"), nil
+	}
+
+	return nil, nil, errors.Wrapf(ErrInvalidKeyType, "unknown key type %s", c.KeyType)
+}
+
+// run generates new key, and writes it to outfile (private key permission 0600, public key 0644),
+// or to stdout if outfile is omitted. Existing key files are never overwritten.
+func (c *cmdKeygen) run() error {
+	if c.OutfileFlag != "" {
+		// Check both files before generating, so that we never write only half of a keypair
+		for _, filename := range []string{c.OutfileFlag, c.OutfileFlag + pubKeySuffix} {
+			if _, err := os.Stat(filename); err == nil {
+				return wrapErrFilename(ErrKeyExists, filename)
+			}
+		}
+	}
+
+	pri, pub, err := c.generate()
+	if err != nil {
+		return errors.Wrap(err, "keygen error")
+	}
+
+	if c.OutfileFlag == "" {
+		return c.writeStdout(pri, pub)
+	}
+
+	if err := writeKeyFile(c.OutfileFlag, pri, 0o600); err != nil {
+		return err
+	}
+
+	if pub == nil {
+		return nil
+	}
+
+	pubFilename := c.OutfileFlag + pubKeySuffix
+	if err := writeKeyFile(pubFilename, pub, 0o644); err != nil {
+		// Never leave only half of a keypair
+		os.Remove(c.OutfileFlag)
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Public key written to %s\n", pubFilename)
+
+	return nil
+}
+
+// writeStdout writes only the private (or symmetric) key pri to stdout, so that it can be redirected
+// to a key file. X25519 and Ed25519 private keys already have their public key as comment,
+// while RSA public key pub is written to stderr.
+func (c *cmdKeygen) writeStdout(pri, pub []byte) error {
+	if _, err := os.Stdout.Write(pri); err != nil {
+		return errors.Wrap(err, "failed to write key to stdout")
+	}
+
+	switch {
+	case pub == nil: // Symmetric key
+
+	case strings.ToUpper(c.KeyType) == rsaKeyTypeValue:
+		fmt.Fprintf(os.Stderr, "Private key PEM written to stdout, public key PEM:\n%s", pub)
+
+	default:
+		fmt.Fprintf(os.Stderr, "Private key written to stdout, with its public key as '# public key:' comment\n")
+	}
+
+	return nil
+}
+
+// writeKeyFile creates new key file with key. The file is removed if it cannot be written completely.
+func writeKeyFile(filename string, key []byte, perm os.FileMode) error {
+	f, err := createKeyFile(filename, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(key); err != nil {
+		f.Close()
+		os.Remove(filename)
+
+		return errors.Wrapf(err, "failed to write key file %s", filename)
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(filename)
+		return errors.Wrapf(err, "failed to close key file %s", filename)
+	}
+
+	return nil
+}
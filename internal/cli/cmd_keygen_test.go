@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeygenHalfKeypair(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "x25519.key")
+
+	// Dangling symlink passes the existence check, but cannot be created as public key file
+	if err := os.Symlink(filepath.Join(dir, "missing"), filename+pubKeySuffix); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &cmdKeygen{KeyType: "x25519", OutfileFlag: filename}
+	if err := c.run(); !errors.Is(err, ErrKeyExists) {
+		t.Fatalf("expecting ErrKeyExists, got %v", err)
+	}
+
+	if _, err := os.Lstat(filename); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expecting private key to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/soyart/gfc/pkg/gfc"
+)
+
+// passphraseFlagValue is the recipient/identity flag value for passphrase
+const passphraseFlagValue = "PASSPHRASE"
+
+type cmdMulti struct {
+	Recipients  []string `arg:"-r,--recipient,separate" placeholder:"RECIPIENT" help:"Recipient: 'passphrase', X25519 public key (GFC-X25519-PUB-...), or RSA public key, X25519 public key list, or 256-bit keyfile filename, can be repeated"`
+	Identities  []string `arg:"-I,--identity,separate" placeholder:"IDENTITY" help:"Identity for decryption: 'passphrase', or RSA private key, X25519 private key list, or 256-bit keyfile filename, can be repeated (prompts for passphrase if omitted)"`
+	PayloadMode string   `arg:"--payload" default:"gcm" placeholder:"[gcm | xcc20]" help:"Payload cipher: AES256-GCM or XChaCha20-Poly1305"`
+	NoStream    bool     `arg:"--no-stream" default:"false" help:"Encrypt file input in one shot instead of chunked stream"`
+
+	baseCommand
+	kdfCommand
+	passphraseCommand
+}
+
+func (c *cmdMulti) algoMode() (gfc.AlgoMode, error) {
+	return gfc.ModeMultiRecipient, nil
+}
+
+func (c *cmdMulti) payload() (gfc.AlgoMode, error) {
+	return parsePayload(c.PayloadMode)
+}
+
+func (c *cmdMulti) stream() bool {
+	return !c.NoStream
+}
+
+// key returns nil, since recipients and identities are passed as options
+func (c *cmdMulti) key() ([]byte, error) {
+	return nil, nil
+}
+
+// options returns recipients for encryption, and identities for decryption
+func (c *cmdMulti) options() ([]gfc.Option, error) {
+	if c.DecryptFlag {
+		identities, err := c.identities()
+		if err != nil {
+			return nil, err
+		}
+
+		return []gfc.Option{gfc.WithIdentities(identities...)}, nil
+	}
+
+	recipients, err := c.recipients()
+	if err != nil {
+		return nil, err
+	}
+
+	return []gfc.Option{gfc.WithRecipients(recipients...)}, nil
+}
+
+func (c *cmdMulti) recipients() ([]gfc.Recipient, error) {
+	if len(c.Recipients) == 0 {
+		return nil, errors.New("missing recipient for multi-recipient encryption")
+	}
+
+	var recipients []gfc.Recipient
+	for _, r := range c.Recipients {
+		switch {
+		case strings.ToUpper(r) == passphraseFlagValue:
+			kdf, err := c.kdf()
+			if err != nil {
+				return nil, errors.Wrap(err, "bad KDF flag")
+			}
+
+			// Passphrase is nil if the user should be prompted
+			passphrase, err := c.readPassphrase()
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to read passphrase")
+			}
+
+			recipients = append(recipients, gfc.RecipientPassphrase(passphrase, kdf))
+			continue
+
+		case strings.HasPrefix(strings.ToUpper(r), gfc.PrefixX25519PublicKey):
+			x25519Recipients, err := gfc.RecipientsX25519([]byte(r))
+			if err != nil {
+				return nil, errors.Wrapf(err, "bad recipient %s", r)
+			}
+
+			recipients = append(recipients, x25519Recipients...)
+			continue
+		}
+
+		key, err := os.ReadFile(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read recipient file")
+		}
+
+		fileRecipients, err := gfc.ParseRecipients(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "bad recipient file %s", r)
+		}
+
+		recipients = append(recipients, fileRecipients...)
+	}
+
+	return recipients, nil
+}
+
+func (c *cmdMulti) identities() ([]gfc.Identity, error) {
+	// Passphrase is nil if the user should be prompted
+	passphrase, err := c.readPassphrase()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read passphrase")
+	}
+
+	if len(c.Identities) == 0 {
+		return []gfc.Identity{gfc.IdentityPassphrase(passphrase)}, nil
+	}
+
+	var identities []gfc.Identity
+	for _, id := range c.Identities {
+		if strings.ToUpper(id) == passphraseFlagValue {
+			identities = append(identities, gfc.IdentityPassphrase(passphrase))
+			continue
+		}
+
+		key, err := os.ReadFile(id)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read identity file")
+		}
+
+		fileIdentities, err := gfc.ParseIdentities(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "bad identity file %s", id)
+		}
+
+		identities = append(identities, fileIdentities...)
+	}
+
+	return identities, nil
+}
+
+//nolint:wrapcheck
+func (c *cmdMulti) crypt(
+	mode gfc.AlgoMode,
+	buf gfc.Buffer,
+	key []byte,
+	decrypt bool,
+	opts ...gfc.Option,
+) (
+	gfc.Buffer,
+	error,
+) {
+	if mode != gfc.ModeMultiRecipient {
+		return nil, errors.Wrapf(gfc.ErrInvalidMode, "invalid multi-recipient mode %s", mode)
+	}
+
+	// Recipients and identities are passed in opts
+	if decrypt {
+		return gfc.DecryptMultiRecipient(buf, nil, opts...)
+	}
+
+	return gfc.EncryptMultiRecipient(buf, nil, opts...)
+}
@@ -1,8 +1,8 @@
 package cli
 
 import (
-	"fmt"
 	"os"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -14,13 +14,34 @@ type cmdRSA struct {
 	PriKey         string `arg:"env:PRI" placeholder:"PRI" help:"Private key string - e.g.: 'PRI=$(< id_rsa) gfc rsa -d ...'"`
 	PubkeyFilename string `arg:"-p,--public-key" placeholder:"PUBFILE" help:"Public key filename"`
 	PriKeyFilename string `arg:"-P,--private-key" placeholder:"PRIFILE" help:"Private key filename"`
+	RSAMode        string `arg:"-m,--mode" default:"hybrid" placeholder:"[hybrid | oaep]" help:"'hybrid' encrypts input of any size, 'oaep' encrypts short input directly with RSA-OEAP"`
+	PayloadMode    string `arg:"--payload" default:"gcm" placeholder:"[gcm | xcc20]" help:"Payload cipher for hybrid mode: AES256-GCM or XChaCha20-Poly1305"`
+	NoStream       bool   `arg:"--no-stream" default:"false" help:"Encrypt file input in one shot instead of chunked stream"`
 
 	baseCommand
 }
 
-// rsaCommand only supports 1 RSA mode for now (OEAP)
 func (c *cmdRSA) algoMode() (gfc.AlgoMode, error) {
-	return gfc.ModeRsaOEAP, nil
+	switch strings.ToUpper(c.RSAMode) {
+	case "HYBRID":
+		return gfc.ModeRsaHybrid, nil
+
+	case "OAEP", "OEAP":
+		return gfc.ModeRsaOEAP, nil
+	}
+
+	return gfc.ModeInvalid, errors.Wrapf(ErrInvalidModeRSA, "unknown mode %s", c.RSAMode)
+}
+
+func (c *cmdRSA) payload() (gfc.AlgoMode, error) {
+	return parsePayload(c.PayloadMode)
+}
+
+// Only hybrid mode is encrypted as chunked stream, while any gfc output can be decrypted from stream
+func (c *cmdRSA) stream() bool {
+	mode, err := c.algoMode()
+
+	return !c.NoStream && err == nil && (c.DecryptFlag || mode == gfc.ModeRsaHybrid)
 }
 
 func (c *cmdRSA) key() ([]byte, error) {
@@ -46,7 +67,7 @@ func (c *cmdRSA) key() ([]byte, error) {
 	case c.PubKey == "" && c.PubkeyFilename == "":
 		return nil, errors.New("missing public key for RSA encryption")
 
-	case c.PubKey == "":
+	case c.PubKey != "":
 		return []byte(c.PubKey), nil
 
 	default:
@@ -65,17 +86,26 @@ func (c *cmdRSA) crypt(
 	buf gfc.Buffer,
 	key []byte,
 	decrypt bool,
+	opts ...gfc.Option,
 ) (
 	gfc.Buffer,
 	error,
 ) {
-	if mode != gfc.ModeRsaOEAP {
-		panic(fmt.Sprintf("invalid RSA mode %d", mode))
-	}
+	switch mode {
+	case gfc.ModeRsaHybrid:
+		if decrypt {
+			return gfc.DecryptRSAHybrid(buf, key, opts...)
+		}
+
+		return gfc.EncryptRSAHybrid(buf, key, opts...)
+
+	case gfc.ModeRsaOEAP:
+		if decrypt {
+			return gfc.DecryptRSA(buf, key, opts...)
+		}
 
-	if decrypt {
-		return gfc.DecryptRSA(buf, key)
+		return gfc.EncryptRSA(buf, key, opts...)
 	}
 
-	return gfc.EncryptRSA(buf, key)
+	return nil, errors.Wrapf(gfc.ErrInvalidMode, "invalid RSA mode %s", mode)
 }
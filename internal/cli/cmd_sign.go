@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/soyart/gfc/pkg/gfc"
+)
+
+type cmdSign struct {
+	PriKey         string `arg:"env:PRI" placeholder:"PRI" help:"Private key string - e.g.: 'PRI=$(< ed25519.key) gfc sign ...'"`
+	PriKeyFilename string `arg:"-P,--private-key" placeholder:"PRIFILE" help:"Ed25519 private key filename"`
+	Attached       bool   `arg:"-a,--attached" default:"false" help:"Write input with attached signature, instead of detached signature"`
+	Keygen         bool   `arg:"--keygen" default:"false" help:"Generate new keypair, same as 'gfc keygen ed25519'"`
+
+	ioCommand
+}
+
+func (c *cmdSign) keygen() bool {
+	return c.Keygen
+}
+
+// keyType returns the key type generated with --keygen
+func (c *cmdSign) keyType() string {
+	return ed25519KeyTypeValue
+}
+
+func (c *cmdSign) key() ([]byte, error) {
+	switch {
+	case c.PriKey == "" && c.PriKeyFilename == "":
+		return nil, errors.New("missing private key for signing")
+
+	case c.PriKey != "":
+		return []byte(c.PriKey), nil
+
+	default:
+		key, err := os.ReadFile(c.PriKeyFilename)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read Ed25519 private key file")
+		}
+
+		return key, nil
+	}
+}
+
+// run signs input, and writes detached signature or signed input to output.
+// Detached signatures of file input are computed without reading the whole input to memory.
+func (c *cmdSign) run() error {
+	if c.keygen() {
+		return runKeygen(c, c.filenameOut())
+	}
+
+	encoding, err := c.encoding()
+	if err != nil {
+		return errors.Wrap(err, "bad encoding flag")
+	}
+
+	key, err := c.key()
+	if err != nil {
+		return errors.Wrap(err, "failed to read key")
+	}
+
+	infile, err := openInput(c.filenameIn(), c.stdinText())
+	if err != nil {
+		return err
+	}
+
+	defer infile.Close()
+
+	out, err := c.sign(infile, key)
+	if err != nil {
+		return errors.Wrap(err, "signing error")
+	}
+
+	out, err = gfc.Encode(encoding, out)
+	if err != nil {
+		return errors.Wrap(err, "output processing failed")
+	}
+
+	outfile, err := openOutput(c.filenameOut(), c.force())
+	if err != nil {
+		return err
+	}
+
+	defer outfile.Close()
+
+	if _, err := out.WriteTo(outfile); err != nil {
+		return errors.Wrapf(err, "failed to write to outfile %s", outfile.Name())
+	}
+
+	return outfile.commit()
+}
+
+func (c *cmdSign) sign(infile *os.File, key []byte) (gfc.Buffer, error) {
+	if c.Attached {
+		buf, err := readInput(infile, c.stdinText())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read input")
+		}
+
+		return gfc.SignAttachedEd25519(buf, key)
+	}
+
+	var message io.Reader = infile
+	if c.stdinText() {
+		buf, err := readInput(infile, true)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read input")
+		}
+
+		message = buf
+	}
+
+	signature, err := gfc.SignEd25519(message, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewBuffer(signature), nil
+}
@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/soyart/gfc/pkg/gfc"
+)
+
+type cmdVerify struct {
+	PubKeys           []string `arg:"-p,--public-key,separate" placeholder:"PUB" help:"Trusted public key (GFC-ED25519-PUB-...) or file with one public key per line, can be repeated"`
+	SignatureFilename string   `arg:"-s,--signature" placeholder:"SIG" help:"Detached signature filename - if omitted, input must have attached signature"`
+
+	ioCommand
+}
+
+// key returns trusted public keys, one per line
+func (c *cmdVerify) key() ([]byte, error) {
+	if len(c.PubKeys) == 0 {
+		return nil, errors.New("missing public key for verification")
+	}
+
+	var publicKeys []string
+	for _, pub := range c.PubKeys {
+		if strings.HasPrefix(strings.ToUpper(pub), gfc.PrefixEd25519PublicKey) {
+			publicKeys = append(publicKeys, pub)
+			continue
+		}
+
+		key, err := os.ReadFile(pub)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read Ed25519 public key file")
+		}
+
+		publicKeys = append(publicKeys, string(key))
+	}
+
+	return []byte(strings.Join(publicKeys, "\n")), nil
+}
+
+// run verifies input against detached signature, or verifies input with attached
+// signature and writes the message to output. The signer is reported to stderr.
+func (c *cmdVerify) run() error {
+	encoding, err := c.encoding()
+	if err != nil {
+		return errors.Wrap(err, "bad encoding flag")
+	}
+
+	key, err := c.key()
+	if err != nil {
+		return errors.Wrap(err, "failed to read key")
+	}
+
+	infile, err := openInput(c.filenameIn(), c.stdinText())
+	if err != nil {
+		return err
+	}
+
+	defer infile.Close()
+
+	var signer string
+	if c.SignatureFilename != "" {
+		signer, err = c.verifyDetached(infile, key, encoding)
+	} else {
+		signer, err = c.verifyAttached(infile, key, encoding)
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "verification error")
+	}
+
+	fmt.Fprintf(os.Stderr, "Good signature from %s\n", signer)
+
+	return nil
+}
+
+func (c *cmdVerify) verifyDetached(infile *os.File, key []byte, encoding gfc.Encoding) (string, error) {
+	raw, err := os.ReadFile(c.SignatureFilename)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read signature file")
+	}
+
+	signature, err := gfc.Decode(encoding, bytes.NewBuffer(raw))
+	if err != nil {
+		return "", errors.Wrap(err, "signature preprocessing failed")
+	}
+
+	var message io.Reader = infile
+	if c.stdinText() {
+		buf, err := readInput(infile, true)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to read input")
+		}
+
+		message = buf
+	}
+
+	return gfc.VerifyEd25519(message, signature.Bytes(), key)
+}
+
+func (c *cmdVerify) verifyAttached(infile *os.File, key []byte, encoding gfc.Encoding) (string, error) {
+	buf, err := readInput(infile, c.stdinText())
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read input")
+	}
+
+	buf, err = gfc.Decode(encoding, buf)
+	if err != nil {
+		return "", errors.Wrap(err, "input preprocessing failed")
+	}
+
+	message, signer, err := gfc.VerifyAttachedEd25519(buf, key)
+	if err != nil {
+		return "", err
+	}
+
+	// Only write verified message
+	outfile, err := openOutput(c.filenameOut(), c.force())
+	if err != nil {
+		return "", err
+	}
+
+	defer outfile.Close()
+
+	if _, err := message.WriteTo(outfile); err != nil {
+		return "", errors.Wrapf(err, "failed to write to outfile %s", outfile.Name())
+	}
+
+	return signer, outfile.commit()
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/soyart/gfc/pkg/gfc"
+)
+
+type cmdX25519 struct {
+	PubKeys        []string `arg:"-p,--public-key,separate" placeholder:"PUB" help:"Recipient public key (GFC-X25519-PUB-...) or file with one public key per line, can be repeated"`
+	PriKey         string   `arg:"env:PRI" placeholder:"PRI" help:"Private key string - e.g.: 'PRI=$(< x25519.key) gfc x25519 -d ...'"`
+	PriKeyFilename string   `arg:"-P,--private-key" placeholder:"PRIFILE" help:"Private key filename, with one or more private keys"`
+	PayloadMode    string   `arg:"--payload" default:"xcc20" placeholder:"[gcm | xcc20]" help:"Payload cipher: AES256-GCM or XChaCha20-Poly1305"`
+	NoStream       bool     `arg:"--no-stream" default:"false" help:"Encrypt file input in one shot instead of chunked stream"`
+	Keygen         bool     `arg:"--keygen" default:"false" help:"Generate new keypair, same as 'gfc keygen x25519'"`
+
+	baseCommand
+}
+
+func (c *cmdX25519) algoMode() (gfc.AlgoMode, error) {
+	return gfc.ModeX25519, nil
+}
+
+func (c *cmdX25519) payload() (gfc.AlgoMode, error) {
+	return parsePayload(c.PayloadMode)
+}
+
+func (c *cmdX25519) stream() bool {
+	return !c.NoStream
+}
+
+func (c *cmdX25519) keygen() bool {
+	return c.Keygen
+}
+
+// keyType returns the key type generated with --keygen
+func (c *cmdX25519) keyType() string {
+	return x25519KeyTypeValue
+}
+
+// key returns recipient public keys for encryption, and private keys for decryption.
+// Both are lists with one key per line.
+func (c *cmdX25519) key() ([]byte, error) {
+	if c.DecryptFlag {
+		switch {
+		case c.PriKey == "" && c.PriKeyFilename == "":
+			return nil, errors.New("missing private key for X25519 decryption")
+
+		case c.PriKey != "":
+			return []byte(c.PriKey), nil
+
+		default:
+			key, err := os.ReadFile(c.PriKeyFilename)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to read X25519 private key file")
+			}
+
+			return key, nil
+		}
+	}
+
+	if len(c.PubKeys) == 0 {
+		return nil, errors.New("missing public key for X25519 encryption")
+	}
+
+	var recipients []string
+	for _, pub := range c.PubKeys {
+		if strings.HasPrefix(strings.ToUpper(pub), gfc.PrefixX25519PublicKey) {
+			recipients = append(recipients, pub)
+			continue
+		}
+
+		key, err := os.ReadFile(pub)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read X25519 public key file")
+		}
+
+		recipients = append(recipients, string(key))
+	}
+
+	return []byte(strings.Join(recipients, "\n")), nil
+}
+
+//nolint:wrapcheck
+func (c *cmdX25519) crypt(
+	mode gfc.AlgoMode,
+	buf gfc.Buffer,
+	key []byte,
+	decrypt bool,
+	opts ...gfc.Option,
+) (
+	gfc.Buffer,
+	error,
+) {
+	if mode != gfc.ModeX25519 {
+		return nil, errors.Wrapf(gfc.ErrInvalidMode, "invalid X25519 mode %s", mode)
+	}
+
+	if decrypt {
+		return gfc.DecryptX25519(buf, key, opts...)
+	}
+
+	return gfc.EncryptX25519(buf, key, opts...)
+}
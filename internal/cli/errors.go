@@ -10,6 +10,20 @@ const (
 	ErrBadOutfileDir
 	ErrOutfileDirNotWritable
 	ErrOutfileNotWritable
+	ErrInvalidKDF
+	ErrInvalidModeRSA
+	ErrInvalidPayload
+	ErrInvalidKeyType
+	ErrKeyExists
+	ErrBadBatch
+	ErrOutfileExists
+	ErrBatchFailed
+	ErrInvalidPassphraseSource
+	ErrInvalidAAD
+	ErrInvalidMetadataFlag
+	ErrNoMetadata
+	ErrInvalidCompression
+	ErrInvalidEncoding
 )
 
 func (err cliError) Error() string {
@@ -34,6 +48,48 @@ func (err cliError) Error() string {
 
 	case ErrOutfileNotWritable:
 		return "missing write permission for outfile"
+
+	case ErrInvalidKDF:
+		return "invalid KDF"
+
+	case ErrInvalidModeRSA:
+		return "invalid RSA mode"
+
+	case ErrInvalidPayload:
+		return "invalid payload mode"
+
+	case ErrInvalidKeyType:
+		return "invalid key type"
+
+	case ErrKeyExists:
+		return "key file already exists"
+
+	case ErrBadBatch:
+		return "bad batch input"
+
+	case ErrOutfileExists:
+		return "outfile already exists"
+
+	case ErrBatchFailed:
+		return "batch failed"
+
+	case ErrInvalidPassphraseSource:
+		return "invalid passphrase source"
+
+	case ErrInvalidAAD:
+		return "invalid associated data"
+
+	case ErrInvalidMetadataFlag:
+		return "invalid metadata flag"
+
+	case ErrNoMetadata:
+		return "input has no metadata"
+
+	case ErrInvalidCompression:
+		return "invalid compression"
+
+	case ErrInvalidEncoding:
+		return "invalid encoding"
 	}
 
 	return "unknown CLI error (should not happen)"
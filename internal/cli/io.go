@@ -3,7 +3,9 @@ package cli
 import (
 	"bufio"
 	"bytes"
+	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/pkg/errors"
 
@@ -32,20 +34,294 @@ func openInput(filenameIn string, stdinText bool) (*os.File, error) {
 	return infile, nil
 }
 
-func openOutput(filenameOut string) (*os.File, error) {
+// openCryptInput opens filenameIn for cmd. When encrypting, directory input is archived
+// on the fly (see gfc.NewArchiveReader), in which case archive is true.
+func openCryptInput(cmd command, filenameIn string) (infile io.ReadCloser, archive bool, err error) {
+	if !cmd.decrypt() && !cmd.stdinText() && isDir(filenameIn) {
+		return gfc.NewArchiveReader(filenameIn), true, nil
+	}
+
+	infile, err = openInput(filenameIn, cmd.stdinText())
+	return infile, false, err
+}
+
+// readsStdin returns if cmd reads its input from stdin
+func readsStdin(cmd command) bool {
+	if b, ok := cmd.(batcher); ok && len(b.batch()) != 0 {
+		return false
+	}
+
+	return cmd.stdinText() || len(cmd.filenameIn()) == 0
+}
+
+func isDir(filename string) bool {
+	if len(filename) == 0 {
+		return false
+	}
+
+	info, err := os.Stat(filename)
+
+	return err == nil && info.IsDir()
+}
+
+// outputFile is output written atomically: data is written to a temporary file in the directory
+// of the output file, which is synced and renamed to the output filename by commit.
+// Closing outputFile without commit removes the temporary file, so a failed run never
+// leaves partial output. Output to stdout is written as it is.
+type outputFile struct {
+	*os.File
+
+	filename  string // Output filename, or empty for stdout
+	force     bool   // Overwrite existing output file
+	committed bool
+}
+
+// openOutput opens output for filenameOut, or stdout if filenameOut is empty.
+// Existing filenameOut is never overwritten, unless force is true.
+// Callers must call commit after writing all output, and close the output.
+func openOutput(filenameOut string, force bool) (*outputFile, error) {
 	if len(filenameOut) == 0 {
-		return os.Stdout, nil
+		return &outputFile{File: os.Stdout}, nil
+	}
+
+	if err := checkOutput(filenameOut, force); err != nil {
+		return nil, err
 	}
 
-	outfile, err := os.OpenFile(filenameOut, os.O_RDWR|os.O_CREATE, os.FileMode(0o600))
+	dir, base := filepath.Split(filenameOut)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+base+".*.tmp")
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to open outfile %s", filenameOut)
+		return nil, errors.Wrapf(err, "failed to create temporary outfile for %s", filenameOut)
+	}
+
+	return &outputFile{File: tmp, filename: filenameOut, force: force}, nil
+}
+
+// Name returns the output filename, not the name of the temporary file
+func (f *outputFile) Name() string {
+	if f.filename == "" {
+		return f.File.Name()
+	}
+
+	return f.filename
+}
+
+// commit syncs the temporary file, and renames it to the output filename
+func (f *outputFile) commit() error {
+	if f.filename == "" || f.committed {
+		return nil
+	}
+
+	if err := f.Sync(); err != nil {
+		return errors.Wrapf(err, "failed to sync outfile %s", f.filename)
+	}
+
+	if err := f.File.Close(); err != nil {
+		return errors.Wrapf(err, "failed to close outfile %s", f.filename)
+	}
+
+	// Output file may have been created while we were writing
+	if err := checkOutput(f.filename, f.force); err != nil {
+		return err
+	}
+
+	if err := os.Rename(f.File.Name(), f.filename); err != nil {
+		return errors.Wrapf(err, "failed to rename temporary outfile to %s", f.filename)
+	}
+
+	f.committed = true
+	syncDir(filepath.Dir(f.filename))
+
+	return nil
+}
+
+// Close closes the output, and removes the temporary file if the output was not committed
+func (f *outputFile) Close() error {
+	if f.filename == "" || f.committed {
+		return nil
+	}
+
+	f.File.Close()
+
+	if err := os.Remove(f.File.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrapf(err, "failed to remove temporary outfile %s", f.File.Name())
+	}
+
+	return nil
+}
+
+// checkOutput returns ErrOutfileExists if filenameOut exists and force is false.
+// Directories are never overwritten.
+func checkOutput(filenameOut string, force bool) error {
+	info, err := os.Lstat(filenameOut)
+	switch {
+	case err != nil:
+		return nil
+
+	case info.IsDir():
+		return wrapErrFilename(ErrFileIsDir, filenameOut)
+
+	case !force:
+		return wrapErrFilename(ErrOutfileExists, filenameOut)
+	}
+
+	return nil
+}
+
+// syncDir syncs directory dir, so that renamed files in it survive a crash.
+// Errors are ignored, since some platforms cannot sync directories.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+
+	defer d.Close()
+
+	_ = d.Sync()
+}
+
+// createKeyFile creates new key file with perm, and never overwrites existing file
+func createKeyFile(filename string, perm os.FileMode) (*os.File, error) {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return nil, wrapErrFilename(ErrKeyExists, filename)
+		}
+
+		return nil, errors.Wrapf(err, "failed to create key file %s", filename)
+	}
+
+	return f, nil
+}
+
+// writeOutput writes decrypted output r of input with header hdr to filenameOut. If the plaintext
+// is a directory archive, it is extracted to directory filenameOut instead. Archive written to stdout
+// is left as tar stream, e.g. for piping to tar. If rs is not nil, metadata is restored to the output.
+// Existing filenameOut is only overwritten if force is true.
+func writeOutput(r io.Reader, filenameOut string, force bool, hdr *gfc.Header, rs *restore) error {
+	if rs != nil {
+		var err error
+		if filenameOut, err = rs.filename(filenameOut, hdr); err != nil {
+			return err
+		}
+	}
+
+	if err := writeOutputFile(r, filenameOut, force, isArchive(hdr)); err != nil {
+		return err
+	}
+
+	if rs != nil {
+		return rs.apply(filenameOut)
+	}
+
+	return nil
+}
+
+func writeOutputFile(r io.Reader, filenameOut string, force bool, archive bool) error {
+	if archive && len(filenameOut) != 0 {
+		return extractOutput(r, filenameOut, force)
+	}
+
+	outfile, err := openOutput(filenameOut, force)
+	if err != nil {
+		return err
+	}
+
+	defer outfile.Close()
+
+	if _, err := io.Copy(outfile, r); err != nil {
+		return errors.Wrapf(err, "failed to write to outfile %s", outfile.Name())
+	}
+
+	return outfile.commit()
+}
+
+// extractOutput extracts archive r to directory dir. The archive is extracted to a temporary directory
+// next to dir first, and renamed to dir on success, so a failed run never leaves partial output.
+// Existing dir is never touched, unless force is true, in which case it is replaced as a whole.
+func extractOutput(r io.Reader, dir string, force bool) error {
+	if err := checkExtractOutput(dir, force); err != nil {
+		return err
+	}
+
+	parent, base := filepath.Split(filepath.Clean(dir))
+	if parent == "" {
+		parent = "."
+	}
+
+	if err := os.MkdirAll(parent, 0o700); err != nil {
+		return errors.Wrapf(err, "failed to create directory %s", parent)
+	}
+
+	tmp, err := os.MkdirTemp(parent, "."+base+".*.tmp")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create temporary directory for %s", dir)
+	}
+
+	if err := gfc.ExtractArchive(r, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return errors.Wrapf(err, "failed to extract archive to %s", dir)
+	}
+
+	// Output directory may have been created while we were extracting
+	if err := checkExtractOutput(dir, force); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	if err := replaceDir(tmp, dir); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	syncDir(parent)
+
+	return nil
+}
+
+// checkExtractOutput returns ErrOutfileExists if dir exists and force is false
+func checkExtractOutput(dir string, force bool) error {
+	if _, err := os.Lstat(dir); err == nil && !force {
+		return wrapErrFilename(ErrOutfileExists, dir)
+	}
+
+	return nil
+}
+
+// replaceDir renames directory tmp to dir. Existing dir is moved aside first,
+// and only removed after tmp is in place, or moved back if renaming tmp fails.
+func replaceDir(tmp, dir string) error {
+	if _, err := os.Lstat(dir); err != nil {
+		if err := os.Rename(tmp, dir); err != nil {
+			return errors.Wrapf(err, "failed to rename temporary directory to %s", dir)
+		}
+
+		return nil
+	}
+
+	old := tmp + ".old"
+	if err := os.Rename(dir, old); err != nil {
+		return errors.Wrapf(err, "failed to move existing output %s aside", dir)
+	}
+
+	if err := os.Rename(tmp, dir); err != nil {
+		os.Rename(old, dir)
+		return errors.Wrapf(err, "failed to rename temporary directory to %s", dir)
+	}
+
+	if err := os.RemoveAll(old); err != nil {
+		return errors.Wrapf(err, "failed to remove old output %s", old)
 	}
 
-	return outfile, nil
+	return nil
 }
 
-func readInput(infile *os.File, stdinText bool) (gfc.Buffer, error) {
+func readInput(infile io.Reader, stdinText bool) (gfc.Buffer, error) {
 	if stdinText {
 		// Read 1 line from stdin
 		scanner := bufio.NewScanner(infile)
@@ -58,7 +334,7 @@ func readInput(infile *os.File, stdinText bool) (gfc.Buffer, error) {
 
 	_, err := input.ReadFrom(infile)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to read from infile %s", infile.Name())
+		return nil, errors.Wrap(err, "failed to read from infile")
 	}
 
 	return input, nil
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/soyart/gfc/pkg/gfc"
+)
+
+func TestOutputFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "out")
+
+	// Uncommitted output leaves nothing behind
+	outfile, err := openOutput(filename, false)
+	if err != nil {
+		t.Fatalf("failed to open output: %s", err.Error())
+	}
+
+	if _, err := outfile.Write([]byte("partial")); err != nil {
+		t.Fatalf("failed to write output: %s", err.Error())
+	}
+
+	outfile.Close()
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expecting empty directory after failed run, got %d entries", len(entries))
+	}
+
+	if err := os.WriteFile(filename, []byte("existing output, longer than new output"), 0o600); err != nil {
+		t.Fatalf("failed to write existing output: %s", err.Error())
+	}
+
+	if _, err := openOutput(filename, false); !errors.Is(err, ErrOutfileExists) {
+		t.Fatalf("expecting ErrOutfileExists, got %v", err)
+	}
+
+	if _, err := openOutput(dir, true); !errors.Is(err, ErrFileIsDir) {
+		t.Fatalf("expecting ErrFileIsDir, got %v", err)
+	}
+
+	// Forced output replaces existing output as a whole
+	outfile, err = openOutput(filename, true)
+	if err != nil {
+		t.Fatalf("failed to open output with force: %s", err.Error())
+	}
+
+	defer outfile.Close()
+
+	if _, err := outfile.Write([]byte("new")); err != nil {
+		t.Fatalf("failed to write output: %s", err.Error())
+	}
+
+	if err := outfile.commit(); err != nil {
+		t.Fatalf("failed to commit output: %s", err.Error())
+	}
+
+	if b, _ := os.ReadFile(filename); string(b) != "new" {
+		t.Fatalf("unexpected output %q", b)
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("expecting only output file, got %d entries", len(entries))
+	}
+}
+
+func TestExtractOutput(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "new.txt"), []byte("new"), 0o600); err != nil {
+		t.Fatalf("failed to write source file: %s", err.Error())
+	}
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+
+	if err := os.Mkdir(out, 0o700); err != nil {
+		t.Fatalf("failed to create existing output: %s", err.Error())
+	}
+
+	if err := os.WriteFile(filepath.Join(out, "old.txt"), []byte("old"), 0o600); err != nil {
+		t.Fatalf("failed to write existing output: %s", err.Error())
+	}
+
+	// Existing output directory is never extracted into
+	if err := extractOutput(gfc.NewArchiveReader(src), out, false); !errors.Is(err, ErrOutfileExists) {
+		t.Fatalf("expecting ErrOutfileExists, got %v", err)
+	}
+
+	// Failed extraction leaves existing output as it is
+	if err := extractOutput(bytes.NewBufferString("not a tar stream"), out, true); err == nil {
+		t.Fatal("expecting error extracting bad archive")
+	}
+
+	if _, err := os.Stat(filepath.Join(out, "old.txt")); err != nil {
+		t.Fatalf("expecting existing output after failed run: %s", err.Error())
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("expecting only output directory after failed run, got %d entries", len(entries))
+	}
+
+	// Forced extraction replaces existing output as a whole
+	if err := extractOutput(gfc.NewArchiveReader(src), out, true); err != nil {
+		t.Fatalf("failed to extract with force: %s", err.Error())
+	}
+
+	if _, err := os.Stat(filepath.Join(out, "old.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expecting old output to be replaced, got %v", err)
+	}
+
+	if b, _ := os.ReadFile(filepath.Join(out, "new.txt")); string(b) != "new" {
+		t.Fatalf("unexpected output %q", b)
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("expecting only output directory, got %d entries", len(entries))
+	}
+}
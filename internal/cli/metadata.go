@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/soyart/gfc/pkg/gfc"
+)
+
+// metadataCommand represents the flags for encrypted metadata of the original file
+type metadataCommand struct {
+	MetadataFlag    bool `arg:"--metadata" default:"false" help:"Encrypt original filename, mode, mtime, and size with the output"`
+	RestoreNameFlag bool `arg:"--restore-name" default:"false" help:"Decrypt to the original filename from metadata, in directory OUT if given"`
+	PreserveFlag    bool `arg:"--preserve" default:"false" help:"Restore original mode and mtime from metadata to decrypted output"`
+}
+
+func (f *metadataCommand) metadata() bool {
+	return f.MetadataFlag
+}
+
+func (f *metadataCommand) restoreName() bool {
+	return f.RestoreNameFlag
+}
+
+func (f *metadataCommand) preserve() bool {
+	return f.PreserveFlag
+}
+
+// restore represents metadata restoration for decrypted output.
+// md is filled in by decryption (see gfc.WithReadMetadata).
+type restore struct {
+	name     bool
+	preserve bool
+	md       gfc.Metadata
+}
+
+// metadataOptions returns opts with options for encrypting metadata of filenameIn, or for reading
+// metadata on decryption. The returned restore is nil if no metadata is restored to decrypted output.
+// opts may be shared by concurrent runs (see batch.go), so it is copied before appending.
+func metadataOptions(
+	cmd command,
+	filenameIn string,
+	filenameOut string,
+	opts []gfc.Option,
+) (
+	[]gfc.Option,
+	*restore,
+	error,
+) {
+	m, ok := cmd.(metadater)
+	if !ok {
+		return opts, nil, nil
+	}
+
+	if !cmd.decrypt() {
+		if m.restoreName() || m.preserve() {
+			return nil, nil, errors.Wrap(ErrInvalidMetadataFlag, "--restore-name and --preserve are only for decryption")
+		}
+
+		if !m.metadata() {
+			return opts, nil, nil
+		}
+
+		if cmd.stdinText() || len(filenameIn) == 0 {
+			return nil, nil, errors.Wrap(ErrInvalidMetadataFlag, "--metadata requires input file")
+		}
+
+		info, err := os.Stat(filenameIn)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to read infile metadata")
+		}
+
+		return append(opts[:len(opts):len(opts)], gfc.WithMetadata(gfc.MetadataFromFileInfo(info))), nil, nil
+	}
+
+	if m.metadata() {
+		return nil, nil, errors.Wrap(ErrInvalidMetadataFlag, "--metadata is only for encryption")
+	}
+
+	if !m.restoreName() && !m.preserve() {
+		return opts, nil, nil
+	}
+
+	if m.preserve() && !m.restoreName() && len(filenameOut) == 0 {
+		return nil, nil, errors.Wrap(ErrInvalidMetadataFlag, "--preserve requires output file")
+	}
+
+	rs := &restore{
+		name:     m.restoreName(),
+		preserve: m.preserve(),
+		md:       gfc.Metadata{Size: -1},
+	}
+
+	return append(opts[:len(opts):len(opts)], gfc.WithReadMetadata(&rs.md)), rs, nil
+}
+
+// filename returns the output filename for decrypted output with header hdr.
+// With --restore-name, it is the original filename in directory filenameOut (or the current directory).
+// Since the name comes from the input, existing files are never overwritten.
+func (rs *restore) filename(filenameOut string, hdr *gfc.Header) (string, error) {
+	if hdr == nil || hdr.Flags&gfc.FlagMetadata == 0 {
+		return "", ErrNoMetadata
+	}
+
+	if !rs.name {
+		return filenameOut, nil
+	}
+
+	if rs.md.Name == "" {
+		return "", errors.Wrap(ErrNoMetadata, "metadata has no filename")
+	}
+
+	dir := "."
+	if len(filenameOut) != 0 {
+		if !isDir(filenameOut) {
+			return "", wrapErrFilename(ErrBadOutfileDir, filenameOut)
+		}
+
+		dir = filenameOut
+	}
+
+	filename := filepath.Join(dir, rs.md.Name)
+	if _, err := os.Lstat(filename); err == nil {
+		return "", wrapErrFilename(ErrOutfileExists, filename)
+	}
+
+	return filename, nil
+}
+
+// apply restores original mode and mtime to filename with --preserve
+func (rs *restore) apply(filename string) error {
+	if !rs.preserve {
+		return nil
+	}
+
+	if err := os.Chmod(filename, rs.md.Mode.Perm()); err != nil {
+		return errors.Wrapf(err, "failed to restore mode of %s", filename)
+	}
+
+	if !rs.md.ModTime.IsZero() {
+		if err := os.Chtimes(filename, rs.md.ModTime, rs.md.ModTime); err != nil {
+			return errors.Wrapf(err, "failed to restore mtime of %s", filename)
+		}
+	}
+
+	return nil
+}
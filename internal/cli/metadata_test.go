@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/soyart/gfc/pkg/gfc"
+)
+
+func TestRestoreFilename(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "exists.txt"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	hdr := &gfc.Header{Flags: gfc.FlagMetadata}
+	rs := &restore{name: true, md: gfc.Metadata{Name: "report.txt"}}
+
+	filename, err := rs.filename(dir, hdr)
+	if err != nil || filename != filepath.Join(dir, "report.txt") {
+		t.Fatalf("unexpected filename %s (%v)", filename, err)
+	}
+
+	// Without --restore-name, outfile is used as it is
+	if filename, err := (&restore{preserve: true}).filename("out.txt", hdr); err != nil || filename != "out.txt" {
+		t.Fatalf("unexpected filename %s (%v)", filename, err)
+	}
+
+	if _, err := rs.filename(dir, &gfc.Header{}); !errors.Is(err, ErrNoMetadata) {
+		t.Fatalf("expecting ErrNoMetadata, got %v", err)
+	}
+
+	if _, err := rs.filename(filepath.Join(dir, "exists.txt"), hdr); !errors.Is(err, ErrBadOutfileDir) {
+		t.Fatalf("expecting ErrBadOutfileDir, got %v", err)
+	}
+
+	rs.md.Name = "exists.txt"
+	if _, err := rs.filename(dir, hdr); !errors.Is(err, ErrOutfileExists) {
+		t.Fatalf("expecting ErrOutfileExists, got %v", err)
+	}
+}
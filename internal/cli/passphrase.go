@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/pkg/errors"
+)
+
+// passphraseCommand represents the flags for reading passphrase non-interactively,
+// shared by subcommands which can use passphrase. If none is given, the user is prompted.
+type passphraseCommand struct {
+	PassphraseFile string `arg:"--passphrase-file" placeholder:"FILE" help:"Read passphrase from the first line of FILE"`
+	PassphraseEnv  string `arg:"--passphrase-env" placeholder:"VAR" help:"Read passphrase from environment variable VAR"`
+	PassphraseFD   *int   `arg:"--passphrase-fd" placeholder:"FD" help:"Read passphrase from the first line of file descriptor FD"`
+	PassphraseCmd  string `arg:"--passphrase-cmd" placeholder:"CMD" help:"Read passphrase from the first line of output of shell command CMD, e.g. a password manager"`
+
+	// Passphrase is read only once, since sources like file descriptors can only be read once
+	passphraseRead bool
+	passphrase     []byte
+}
+
+// readPassphrase returns passphrase from the source given in flags, or nil if no source is given
+func (f *passphraseCommand) readPassphrase() ([]byte, error) {
+	if f.passphraseRead {
+		return f.passphrase, nil
+	}
+
+	var sources int
+	for _, given := range []bool{f.PassphraseFile != "", f.PassphraseEnv != "", f.PassphraseFD != nil, f.PassphraseCmd != ""} {
+		if given {
+			sources++
+		}
+	}
+
+	if sources > 1 {
+		return nil, errors.Wrap(ErrInvalidPassphraseSource, "only one passphrase source can be given")
+	}
+
+	var passphrase []byte
+	var err error
+
+	switch {
+	case f.PassphraseFile != "":
+		passphrase, err = passphraseFile(f.PassphraseFile)
+
+	case f.PassphraseEnv != "":
+		value, ok := os.LookupEnv(f.PassphraseEnv)
+		if !ok {
+			return nil, errors.Wrapf(ErrInvalidPassphraseSource, "environment variable %s is not set", f.PassphraseEnv)
+		}
+
+		passphrase = []byte(value)
+
+	case f.PassphraseFD != nil:
+		fd := os.NewFile(uintptr(*f.PassphraseFD), "passphrase")
+		if fd == nil {
+			return nil, errors.Wrapf(ErrInvalidPassphraseSource, "bad file descriptor %d", *f.PassphraseFD)
+		}
+
+		defer fd.Close()
+
+		passphrase, err = firstLine(fd)
+
+	case f.PassphraseCmd != "":
+		passphrase, err = passphraseCmd(f.PassphraseCmd)
+
+	default:
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(passphrase) == 0 {
+		return nil, errors.Wrap(ErrInvalidPassphraseSource, "empty passphrase")
+	}
+
+	f.passphrase, f.passphraseRead = passphrase, true
+
+	return passphrase, nil
+}
+
+func (f *passphraseCommand) passphraseStdin() bool {
+	return f.PassphraseFD != nil && *f.PassphraseFD == 0
+}
+
+func passphraseFile(filename string) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open passphrase file %s", filename)
+	}
+
+	defer f.Close()
+
+	return firstLine(f)
+}
+
+// passphraseCmd runs command with stdin from /dev/null, so that it cannot consume
+// gfc input piped to stdin. Commands which prompt can still use /dev/tty.
+func passphraseCmd(command string) ([]byte, error) {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidPassphraseSource, "passphrase command failed: %s", err.Error())
+	}
+
+	return firstLine(bytes.NewReader(out))
+}
+
+// firstLine reads the first line of r, without the line ending
+func firstLine(r io.Reader) ([]byte, error) {
+	line, err := bufio.NewReader(r).ReadBytes('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.Wrap(err, "failed to read passphrase")
+	}
+
+	return bytes.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexflint/go-arg"
+)
+
+func TestReadPassphrase(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "passphrase")
+	if err := os.WriteFile(filename, []byte("from file\r\nsecond line\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GFC_TEST_PASSPHRASE", "from env")
+
+	tests := map[string]struct {
+		cmd      passphraseCommand
+		expected string
+	}{
+		"file":    {cmd: passphraseCommand{PassphraseFile: filename}, expected: "from file"},
+		"env":     {cmd: passphraseCommand{PassphraseEnv: "GFC_TEST_PASSPHRASE"}, expected: "from env"},
+		"command": {cmd: passphraseCommand{PassphraseCmd: "echo from command"}, expected: "from command"},
+		"none":    {cmd: passphraseCommand{}, expected: ""},
+	}
+
+	for name, test := range tests {
+		passphrase, err := test.cmd.readPassphrase()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err.Error())
+		}
+
+		if string(passphrase) != test.expected {
+			t.Fatalf("%s: expecting passphrase %q, got %q", name, test.expected, passphrase)
+		}
+	}
+
+	bad := map[string]passphraseCommand{
+		"multiple sources": {PassphraseFile: filename, PassphraseEnv: "GFC_TEST_PASSPHRASE"},
+		"unset env":        {PassphraseEnv: "GFC_TEST_PASSPHRASE_UNSET"},
+		"failed command":   {PassphraseCmd: "exit 1"},
+		"empty passphrase": {PassphraseCmd: "true"},
+	}
+
+	for name, cmd := range bad {
+		if _, err := cmd.readPassphrase(); !errors.Is(err, ErrInvalidPassphraseSource) {
+			t.Fatalf("%s: expecting ErrInvalidPassphraseSource, got %v", name, err)
+		}
+	}
+}
+
+func TestPassphraseCmdStdin(t *testing.T) {
+	// Passphrase command must not read gfc input from stdin
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.Close()
+
+	if _, err := w.WriteString("piped input\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Close()
+
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	passphrase, err := passphraseCmd("cat; echo from command")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if string(passphrase) != "from command" {
+		t.Fatalf("expecting passphrase %q, got %q", "from command", passphrase)
+	}
+}
+
+func TestPassphraseFDStdin(t *testing.T) {
+	infile := filepath.Join(t.TempDir(), "plain")
+	if err := os.WriteFile(infile, []byte("plaintext"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only commands rejected before reading passphrase run cryptOptions, so that the test never reads stdin
+	tests := map[string]struct {
+		args     []string
+		rejected bool
+	}{
+		"stdin input": {args: []string{"aes", "--passphrase-fd", "0"}, rejected: true},
+		"text input":  {args: []string{"aes", "--passphrase-fd", "0", "-t"}, rejected: true},
+		"file input":  {args: []string{"aes", "--passphrase-fd", "0", "-i", infile}},
+		"other fd":    {args: []string{"aes", "--passphrase-fd", "3"}},
+	}
+
+	for name, test := range tests {
+		g := new(Gfc)
+
+		p, err := arg.NewParser(arg.Config{}, g)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := p.Parse(test.args); err != nil {
+			t.Fatalf("%s: failed to parse args: %s", name, err.Error())
+		}
+
+		cmd := g.CommandAES
+		if rejected := cmd.passphraseStdin() && readsStdin(cmd); rejected != test.rejected {
+			t.Fatalf("%s: expecting rejected %v, got %v", name, test.rejected, rejected)
+		}
+
+		if !test.rejected {
+			continue
+		}
+
+		if _, err := cryptOptions(cmd); !errors.Is(err, ErrInvalidPassphraseSource) {
+			t.Fatalf("%s: expecting ErrInvalidPassphraseSource, got %v", name, err)
+		}
+	}
+}
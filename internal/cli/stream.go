@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/soyart/gfc/pkg/gfc"
+)
+
+// useStream checks if cmd should process its input as stream.
+// Only file input is streamed, so that the output of short text
+// and stdin input stays the same as before.
+func useStream(cmd command, filenameIn string) bool {
+	s, ok := cmd.(streamer)
+	if !ok || !s.stream() {
+		return false
+	}
+
+	return !cmd.stdinText() && len(filenameIn) != 0
+}
+
+// runStream pre-processes, encrypts/decrypts, and post-processes infile to filenameOut
+// as stream, without reading the whole input to memory.
+func runStream(
+	cmd command,
+	mode gfc.AlgoMode,
+	key []byte,
+	infile io.Reader,
+	filenameOut string,
+	opts []gfc.Option,
+	rs *restore,
+) error {
+	encoding, err := cmd.encoding()
+	if err != nil {
+		return errors.Wrap(err, "bad encoding flag")
+	}
+
+	compression, level, err := cmd.compression()
+	if err != nil {
+		return errors.Wrap(err, "bad compression flag")
+	}
+
+	if cmd.decrypt() {
+		if cmd.detectEncoding() {
+			if infile, encoding, err = gfc.SniffEncoding(infile); err != nil {
+				return errors.Wrap(err, "input preprocessing failed")
+			}
+		}
+
+		decoder, err := gfc.NewDecodeReader(infile, encoding)
+		if err != nil {
+			return errors.Wrap(err, "input preprocessing failed")
+		}
+
+		hdr, ciphertext, err := gfc.PeekHeader(decoder)
+		if err != nil {
+			return errors.Wrap(err, "bad gfc header")
+		}
+
+		// Mode is read from gfc header, and is only used for output without header.
+		// opts may be shared by concurrent runs (see batch.go), so it is copied before appending.
+		decrypter, err := gfc.NewDecryptReader(ciphertext, key, append(opts[:len(opts):len(opts)], gfc.WithMode(mode))...)
+		if err != nil {
+			return errors.Wrap(err, "cryptography error")
+		}
+
+		// Compression is read from gfc header unless given with flags.
+		// Output without header (older gfc) is only decompressed with compression flags.
+		if hdr != nil && cmd.detectCompression() {
+			compression = hdr.Compression
+		}
+
+		decompressor, err := gfc.NewDecompressReader(decrypter, compression)
+		if err != nil {
+			return errors.Wrap(err, "output processing failed")
+		}
+
+		defer decompressor.Close()
+
+		if err := writeOutput(decompressor, filenameOut, cmd.force(), hdr, rs); err != nil {
+			return errors.Wrap(err, "failed to decrypt stream")
+		}
+
+		return nil
+	}
+
+	outfile, err := openOutput(filenameOut, cmd.force())
+	if err != nil {
+		return err
+	}
+
+	defer outfile.Close()
+
+	encoder, err := gfc.NewEncodeWriter(outfile, encoding)
+	if err != nil {
+		return errors.Wrap(err, "output processing failed")
+	}
+
+	encrypter, err := gfc.NewEncryptWriter(encoder, mode, key, opts...)
+	if err != nil {
+		return errors.Wrap(err, "cryptography error")
+	}
+
+	compressor, err := gfc.NewCompressWriter(encrypter, compression, level)
+	if err != nil {
+		return errors.Wrap(err, "input preprocessing failed")
+	}
+
+	if _, err := io.Copy(compressor, infile); err != nil {
+		return errors.Wrap(err, "failed to encrypt stream")
+	}
+
+	// Close in pipeline order, so that each stage flushes to the next
+	for _, w := range []io.Closer{compressor, encrypter, encoder} {
+		if err := w.Close(); err != nil {
+			return errors.Wrap(err, "failed to flush stream")
+		}
+	}
+
+	return outfile.commit()
+}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
+
+	"github.com/soyart/gfc/pkg/gfc"
 )
 
 // TODO: UNIX only - Windows not supported
@@ -15,3 +17,13 @@ func isWritable(info os.FileInfo) bool {
 func wrapErrFilename(err error, fname string) error {
 	return errors.Wrapf(err, "bad file '%s'", fname)
 }
+
+// readKeyfile reads 256-bit keyfile, which may be raw, or hex or base64-encoded by gfc keygen
+func readKeyfile(filename string) ([]byte, error) {
+	key, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return gfc.DecodeKeyfile(key), nil
+}
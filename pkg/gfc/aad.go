@@ -0,0 +1,52 @@
+package gfc
+
+// This file provides additional authenticated data (AAD) given with WithAAD.
+// AAD is not written to the output, only FlagAAD is set in the header.
+// Every mode authenticates the serialized header, and with AAD, the header is followed by
+// the AAD length and the AAD itself:
+//
+//	<Header> <AAD length (8 bytes, big endian)> <AAD>
+//
+// This is used as AEAD additional data, HMAC input of AES256-CTR (before ciphertext),
+// and OAEP label of RSA256-OEAP. The length prefix keeps the AAD boundary unambiguous for HMAC.
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// associatedData returns data authenticated with ciphertext following serialized header
+func associatedData(header []byte, o *options) []byte {
+	if len(o.aad) == 0 {
+		return header
+	}
+
+	ad := make([]byte, 0, len(header)+8+len(o.aad))
+	ad = append(ad, header...)
+	ad = binary.BigEndian.AppendUint64(ad, uint64(len(o.aad)))
+
+	return append(ad, o.aad...)
+}
+
+// checkAAD returns ErrAAD if AAD from o is missing or not expected for ciphertext with hdr.
+// A nil hdr means output written before header was introduced, which never has AAD.
+func checkAAD(hdr *Header, o *options) error {
+	hasAAD := len(o.aad) != 0
+
+	switch {
+	case hdr == nil && hasAAD:
+		return errors.Wrap(ErrAAD, "output without header has no associated data")
+
+	case hdr == nil:
+		return nil
+
+	case hdr.Flags&FlagAAD != 0 && !hasAAD:
+		return errors.Wrap(ErrAAD, "ciphertext is bound to associated data, but none was given")
+
+	case hdr.Flags&FlagAAD == 0 && hasAAD:
+		return errors.Wrap(ErrAAD, "ciphertext is not bound to associated data, but some was given")
+	}
+
+	return nil
+}
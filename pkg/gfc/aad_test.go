@@ -0,0 +1,88 @@
+package gfc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestAAD(t *testing.T) {
+	key := make([]byte, aes256BitKeyFileLen)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("error filling random key bytes: %s", err.Error())
+	}
+
+	pubPEM, err := os.ReadFile("../../assets/files/pub.pem")
+	if err != nil {
+		t.Fatalf("error reading public key: %s", err.Error())
+	}
+
+	priPEM, err := os.ReadFile("../../assets/files/pri.pem")
+	if err != nil {
+		t.Fatalf("error reading private key: %s", err.Error())
+	}
+
+	pubX25519, priX25519, err := GenerateX25519()
+	if err != nil {
+		t.Fatalf("failed to generate X25519 keypair: %s", err.Error())
+	}
+
+	type cryptFunc func(Buffer, []byte, ...Option) (Buffer, error)
+
+	tests := map[string]struct {
+		encrypt    cryptFunc
+		decrypt    cryptFunc
+		encryptKey []byte
+		decryptKey []byte
+		opts       []Option
+	}{
+		"AES256-GCM":         {EncryptGCM, DecryptGCM, key, key, nil},
+		"AES256-GCM chunked": {EncryptGCM, DecryptGCM, key, key, []Option{WithChunkSize(16)}},
+		"AES256-CTR":         {EncryptCTR, DecryptCTR, key, key, nil},
+		"XChaCha20-Poly1305": {EncryptXChaCha20Poly1305, DecryptXChaCha20Poly1305, key, key, nil},
+		"ChaCha20-Poly1305":  {EncryptChaCha20Poly1305, DecryptChaCha20Poly1305, key, key, nil},
+		"RSA256-OEAP":        {EncryptRSA, DecryptRSA, pubPEM, priPEM, nil},
+		"RSA256-OEAP-Hybrid": {EncryptRSAHybrid, DecryptRSAHybrid, pubPEM, priPEM, nil},
+		"X25519-Hybrid":      {EncryptX25519, DecryptX25519, []byte(pubX25519), []byte(priX25519), nil},
+	}
+
+	plaintext := []byte("this is my plaintext")
+	aad := []byte("row 1")
+
+	for name, test := range tests {
+		opts := append(test.opts[:len(test.opts):len(test.opts)], WithAAD(aad))
+
+		ciphertext, err := test.encrypt(bytes.NewBuffer(plaintext), test.encryptKey, opts...)
+		if err != nil {
+			t.Fatalf("%s: error encrypting: %s", name, err.Error())
+		}
+
+		decrypted, err := test.decrypt(bytes.NewBuffer(ciphertext.Bytes()), test.decryptKey, WithAAD(aad))
+		if err != nil {
+			t.Fatalf("%s: error decrypting: %s", name, err.Error())
+		}
+
+		if !bytes.Equal(decrypted.Bytes(), plaintext) {
+			t.Fatalf("%s: output does not match", name)
+		}
+
+		if _, err := test.decrypt(bytes.NewBuffer(ciphertext.Bytes()), test.decryptKey, WithAAD([]byte("row 2"))); !errors.Is(err, ErrAuth) {
+			t.Fatalf("%s: wrong AAD: expecting ErrAuth, got %v", name, err)
+		}
+
+		if _, err := test.decrypt(bytes.NewBuffer(ciphertext.Bytes()), test.decryptKey); !errors.Is(err, ErrAAD) {
+			t.Fatalf("%s: missing AAD: expecting ErrAAD, got %v", name, err)
+		}
+
+		ciphertext, err = test.encrypt(bytes.NewBuffer(plaintext), test.encryptKey, test.opts...)
+		if err != nil {
+			t.Fatalf("%s: error encrypting without AAD: %s", name, err.Error())
+		}
+
+		if _, err := test.decrypt(bytes.NewBuffer(ciphertext.Bytes()), test.decryptKey, WithAAD(aad)); !errors.Is(err, ErrAAD) {
+			t.Fatalf("%s: unexpected AAD: expecting ErrAAD, got %v", name, err)
+		}
+	}
+}
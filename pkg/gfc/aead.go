@@ -0,0 +1,208 @@
+package gfc
+
+// This file provides encryption shared by gfc AEAD ciphers,
+// i.e. AES256-GCM, XChaCha20-Poly1305, and ChaCha20-Poly1305.
+// AEAD output is either sealed in one shot:
+//
+//	<Header> <Ciphertext>
+//
+// or as chunked stream (see stream.go). The serialized header
+// is always authenticated as additional data.
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// lenTagAEAD is the tag length of all AEAD ciphers supported by gfc
+const lenTagAEAD = 16
+
+// aeadSpec describes how to create AEAD cipher for a mode
+type aeadSpec struct {
+	newCipher func([]byte) (cipher.AEAD, error)
+	nonceSize int
+	errOpen   Error
+}
+
+func aeadSpecFor(mode AlgoMode) (aeadSpec, error) {
+	switch mode {
+	case ModeAesGCM:
+		return aeadSpec{newCipher: newCipherGCM, nonceSize: lenNonceAESGCM256, errOpen: ErrOpenGCM}, nil
+
+	case ModeXChaCha20Poly1305:
+		return aeadSpec{newCipher: newCipherChaCha20(chacha20poly1305.NewX), nonceSize: chacha20poly1305.NonceSizeX, errOpen: ErrOpenXChaCha20Poly1305}, nil
+
+	case ModeChaCha20Poly1305:
+		return aeadSpec{newCipher: newCipherChaCha20(chacha20poly1305.New), nonceSize: chacha20poly1305.NonceSize, errOpen: ErrOpenXChaCha20Poly1305}, nil
+	}
+
+	return aeadSpec{}, errors.Wrapf(ErrInvalidMode, "%s is not an AEAD mode", mode)
+}
+
+// aeadSpecOptions returns aeadSpec for mode, unless overridden in o
+func aeadSpecOptions(mode AlgoMode, o *options) (aeadSpec, error) {
+	if o.aead != nil {
+		return *o.aead, nil
+	}
+
+	return aeadSpecFor(mode)
+}
+
+// headerNonceSize returns the length of header nonce for AEAD with spec,
+// which is only the nonce prefix for chunked output.
+func (spec aeadSpec) headerNonceSize(chunkSize uint32) int {
+	if chunkSize != 0 {
+		return spec.nonceSize - lenStreamNonceSuffix
+	}
+
+	return spec.nonceSize
+}
+
+func newEncryptWriterAEAD(w io.Writer, mode AlgoMode, key []byte, o *options) (io.WriteCloser, error) {
+	spec, err := aeadSpecOptions(mode, o)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.chunkSize > maxChunkSize {
+		return nil, errors.Wrapf(ErrStreamChunkSize, "chunk size %d", o.chunkSize)
+	}
+
+	hdr, header, key, err := newHeaderSymm(mode, spec.headerNonceSize(o.chunkSize), o.chunkSize, key, o)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s encryption", mode)
+	}
+
+	aead, err := spec.newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return nil, errors.Wrap(err, "failed to write header")
+	}
+
+	return newAEADWriter(w, aead, hdr, associatedData(header, o)), nil
+}
+
+func newDecryptReaderAEAD(r io.Reader, hdr *Header, aad []byte, key []byte, o *options) (io.Reader, error) {
+	spec, err := aeadSpecOptions(hdr.Mode, o)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err = keyDecryptSymm(hdr, key, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAEADReader(r, spec, hdr, aad, key)
+}
+
+// newAEADWriter returns a writer which encrypts to w with aead, after the header was written.
+// Output is chunked if header ChunkSize is not 0, otherwise it is sealed in one shot on Close.
+func newAEADWriter(w io.Writer, aead cipher.AEAD, hdr *Header, aad []byte) io.WriteCloser {
+	if hdr.ChunkSize != 0 {
+		return newStreamWriter(w, aead, hdr, aad)
+	}
+
+	return &aeadWriter{
+		w:     w,
+		aead:  aead,
+		aad:   aad,
+		nonce: hdr.Nonce,
+	}
+}
+
+// newAEADReader returns a reader which decrypts ciphertext following the header from r with key
+func newAEADReader(r io.Reader, spec aeadSpec, hdr *Header, aad []byte, key []byte) (io.Reader, error) {
+	nonceSize := spec.headerNonceSize(hdr.ChunkSize)
+	if lenNonce := len(hdr.Nonce); lenNonce != nonceSize {
+		return nil, errors.Wrapf(ErrUnmarshalHeader, "bad nonce length for %s - expecting %d, got %d", hdr.payloadMode(), nonceSize, lenNonce)
+	}
+
+	aead, err := spec.newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if hdr.ChunkSize != 0 {
+		return newStreamReader(r, aead, hdr, aad), nil
+	}
+
+	ciphertext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read ciphertext")
+	}
+
+	if len(ciphertext) < lenTagAEAD {
+		return nil, errors.Wrapf(ErrTruncated, "input too short for %s: need at least %d bytes after header, got %d", hdr.payloadMode(), lenTagAEAD, len(ciphertext))
+	}
+
+	plaintext, err := aead.Open(nil, hdr.Nonce, ciphertext, aad)
+	if err != nil {
+		return nil, errors.Wrap(spec.errOpen, err.Error())
+	}
+
+	return bytes.NewReader(plaintext), nil
+}
+
+func decryptLegacyAEAD(ciphertext []byte, key []byte, o *options) (io.Reader, error) {
+	spec, err := aeadSpecOptions(o.mode, o)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := decodeLegacyOutputGfcSymm(ciphertext, key, o.mode, spec.nonceSize, lenTagAEAD, o)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrUnmarshalSymmAEAD.Error())
+	}
+
+	aead, err := spec.newCipher(out.key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, out.nonce, out.ciphertext, nil)
+	if err != nil {
+		return nil, errors.Wrap(spec.errOpen, err.Error())
+	}
+
+	return bytes.NewReader(plaintext), nil
+}
+
+// aeadWriter buffers plaintext, and seals it in one shot on Close
+type aeadWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	aad    []byte
+	nonce  []byte
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (a *aeadWriter) Write(p []byte) (int, error) {
+	if a.closed {
+		return 0, ErrStreamClosed
+	}
+
+	return a.buf.Write(p)
+}
+
+func (a *aeadWriter) Close() error {
+	if a.closed {
+		return nil
+	}
+
+	a.closed = true
+
+	if _, err := a.w.Write(a.aead.Seal(nil, a.nonce, a.buf.Bytes(), a.aad)); err != nil {
+		return errors.Wrap(err, "failed to write ciphertext")
+	}
+
+	return nil
+}
@@ -4,104 +4,241 @@ package gfc
 // CTR converts a block cipher into a stream cipher by
 // repeatedly encrypting an incrementing counter and
 // xoring the resulting stream of data with the input.
-// In gfc, this mode does not authenticate decrypted message
-// so I recommend you use GCM (default mode for gfc).
 // See https://golang.org/src/crypto/cipher/ctr.go
+//
+// CTR itself does not authenticate decrypted message, so gfc appends
+// an HMAC-SHA256 tag (encrypt-then-MAC) over the header (which includes
+// the IV and salt), AAD if any (see aad.go), and the ciphertext:
+//
+//	<Header> <Ciphertext> <HMAC-SHA256 tag (32 bytes)>
+//
+// Separate encryption and MAC subkeys are derived from the key with HKDF-SHA256.
+// The tag is verified before any plaintext is returned, so decryption reads
+// the whole ciphertext to memory.
+//
+// Unauthenticated CTR output written by older gfc (ModeAesCTRLegacy, or output
+// without header) can only be decrypted with WithLegacyCTR, or with DecryptLegacyCTR.
+// I still recommend you use GCM (default mode for gfc).
 
 import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
+	"crypto/hmac"
+	"crypto/sha256"
+	"hash"
 	"io"
 
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/hkdf"
 )
 
-const blockSizeAES256CTR = 16
+const (
+	blockSizeAES256CTR = 16
+	lenTagCTR          = sha256.Size
 
-func EncryptCTR(plaintext Buffer, aesKey []byte) (Buffer, error) {
-	key, salt, err := keySaltPBKDF2(aesKey, nil)
+	infoEncKeyCTR = "gfc AES256-CTR encryption key"
+	infoMACKeyCTR = "gfc AES256-CTR HMAC-SHA256 key"
+)
+
+func newStreamCTR(key, iv []byte) (cipher.Stream, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
-		err = errors.Wrap(err, ErrPBKDF2KeySalt.Error())
+		return nil, errors.Wrap(ErrNewCipherCTR, err.Error())
+	}
+
+	return cipher.NewCTR(block, iv), nil
+}
+
+// subkeysCTR derives encryption and MAC subkeys from key
+func subkeysCTR(key []byte) ([]byte, []byte, error) {
+	encKey := make([]byte, aes256BitKeyFileLen)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte(infoEncKeyCTR)), encKey); err != nil {
+		return nil, nil, errors.Wrap(err, "HKDF failed")
+	}
+
+	macKey := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte(infoMACKeyCTR)), macKey); err != nil {
+		return nil, nil, errors.Wrap(err, "HKDF failed")
+	}
+
+	return encKey, macKey, nil
+}
+
+// ctrWriter encrypts with CTR, and writes HMAC tag of header and ciphertext on Close
+type ctrWriter struct {
+	w      io.Writer
+	stream cipher.Stream
+	mac    hash.Hash
+	buf    []byte
+	closed bool
+}
+
+func (w *ctrWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrStreamClosed
+	}
+
+	if cap(w.buf) < len(p) {
+		w.buf = make([]byte, len(p))
+	}
+
+	ciphertext := w.buf[:len(p)]
+	w.stream.XORKeyStream(ciphertext, p)
+	w.mac.Write(ciphertext)
 
+	if _, err := w.w.Write(ciphertext); err != nil {
+		return 0, errors.Wrap(err, "failed to write ciphertext")
+	}
+
+	return len(p), nil
+}
+
+func (w *ctrWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+	if _, err := w.w.Write(w.mac.Sum(nil)); err != nil {
+		return errors.Wrap(err, "failed to write HMAC tag")
+	}
+
+	return nil
+}
+
+func newEncryptWriterCTR(w io.Writer, aesKey []byte, o *options) (io.WriteCloser, error) {
+	// blockSize is 16, and the IV is stored as header nonce
+	hdr, header, key, err := newHeaderSymm(ModeAesCTR, blockSizeAES256CTR, 0, aesKey, o)
+	if err != nil {
 		return nil, errors.Wrap(err, "AES256-CTR encryption")
 	}
 
-	block, err := aes.NewCipher(key)
+	encKey, macKey, err := subkeysCTR(key)
 	if err != nil {
-		return nil, errors.Wrap(err, ErrNewCipherCTR.Error())
+		return nil, err
 	}
 
-	// blockSize is 16
-	iv := make([]byte, blockSizeAES256CTR)
-	rand.Read(iv)
+	stream, err := newStreamCTR(encKey, hdr.Nonce)
+	if err != nil {
+		return nil, err
+	}
 
-	stream := cipher.NewCTR(block, iv)
-	ciphertext := new(bytes.Buffer)
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(associatedData(header, o))
 
-	// We will be using a byte buffer of size 1024
-	buf := make([]byte, 1024)
-	for {
-		// Read n bytes from plaintext to buf
-		n, err := plaintext.Read(buf)
-		if n > 0 {
-			stream.XORKeyStream(buf, buf[:n])
-			// Write buf[:n] to ciphertext
-			ciphertext.Write(buf[:n])
-		}
+	if _, err := w.Write(header); err != nil {
+		return nil, errors.Wrap(err, "failed to write header")
+	}
 
-		if err == io.EOF {
-			break
-		}
+	return &ctrWriter{w: w, stream: stream, mac: mac}, nil
+}
 
-		if err != nil {
-			return nil, errors.Wrap(err, ErrReadCTR.Error())
-		}
+func newDecryptReaderCTR(r io.Reader, hdr *Header, aad []byte, aesKey []byte, o *options) (io.Reader, error) {
+	if lenIV := len(hdr.Nonce); lenIV != blockSizeAES256CTR {
+		return nil, errors.Wrapf(ErrUnmarshalHeader, "bad IV length for AES256-CTR - expecting %d, got %d", blockSizeAES256CTR, lenIV)
 	}
 
-	return formatOutputGfcSymm(
-		ciphertext.Bytes(),
-		iv,
-		salt,
-	)
+	key, err := keyDecryptSymm(hdr, aesKey, o)
+	if err != nil {
+		return nil, err
+	}
+
+	encKey, macKey, err := subkeysCTR(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read ciphertext")
+	}
+
+	if len(ciphertext) < lenTagCTR {
+		return nil, errors.Wrap(ErrAuthCTR, "missing HMAC tag")
+	}
+
+	tag := ciphertext[len(ciphertext)-lenTagCTR:]
+	ciphertext = ciphertext[:len(ciphertext)-lenTagCTR]
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(aad)
+	mac.Write(ciphertext)
+
+	if !hmac.Equal(mac.Sum(nil), tag) {
+		return nil, ErrAuthCTR
+	}
+
+	stream, err := newStreamCTR(encKey, hdr.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	stream.XORKeyStream(plaintext, ciphertext)
+
+	return bytes.NewReader(plaintext), nil
+}
+
+// newDecryptReaderCTRLegacy decrypts unauthenticated CTR output with header
+func newDecryptReaderCTRLegacy(r io.Reader, hdr *Header, aesKey []byte, o *options) (io.Reader, error) {
+	if !o.legacyCTR {
+		return nil, errors.Wrap(ErrLegacyCTR, "use WithLegacyCTR to decrypt unauthenticated AES256-CTR output")
+	}
+
+	if lenIV := len(hdr.Nonce); lenIV != blockSizeAES256CTR {
+		return nil, errors.Wrapf(ErrUnmarshalHeader, "bad IV length for AES256-CTR - expecting %d, got %d", blockSizeAES256CTR, lenIV)
+	}
+
+	key, err := keyDecryptSymm(hdr, aesKey, o)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := newStreamCTR(key, hdr.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.StreamReader{S: stream, R: r}, nil
 }
 
-func DecryptCTR(ciphertext Buffer, aesKey []byte) (Buffer, error) {
-	lenMsg, _, key, iv, err := decodeOutputGfcSymm(ciphertext, aesKey, blockSizeAES256CTR)
+func decryptLegacyCTR(ciphertext []byte, aesKey []byte, o *options) (io.Reader, error) {
+	if !o.legacyCTR {
+		return nil, errors.Wrap(ErrLegacyCTR, "use WithLegacyCTR to decrypt AES256-CTR output without header")
+	}
+
+	out, err := decodeLegacyOutputGfcSymm(ciphertext, aesKey, ModeAesCTR, blockSizeAES256CTR, 0, o)
 	if err != nil {
 		return nil, errors.Wrap(err, ErrUnmarshalSymmAEAD.Error())
 	}
 
-	block, err := aes.NewCipher(key)
+	stream, err := newStreamCTR(out.key, out.nonce)
 	if err != nil {
-		return nil, errors.Wrap(err, ErrNewCipherCTR.Error())
+		return nil, err
 	}
 
-	stream := cipher.NewCTR(block, iv)
-	buf := make([]byte, 1024)
-	plaintext := new(bytes.Buffer)
-
-	for {
-		n, err := ciphertext.Read(buf)
-		if n > 0 {
-			if n > lenMsg {
-				n = lenMsg
-			}
+	return cipher.StreamReader{S: stream, R: bytes.NewReader(out.ciphertext)}, nil
+}
 
-			lenMsg -= n
-			stream.XORKeyStream(buf, buf[:n])
-			plaintext.Write(buf[:n])
-		}
+// EncryptCTR encrypts plaintext with AES256-CTR and HMAC-SHA256
+func EncryptCTR(plaintext Buffer, aesKey []byte, opts ...Option) (Buffer, error) {
+	return encryptBuffer(ModeAesCTR, plaintext, aesKey, opts)
+}
 
-		if err == io.EOF {
-			break
-		}
+// DecryptCTR decrypts AES256-CTR output, and fails with ErrAuthCTR if ciphertext was tampered with.
+// Unauthenticated output written by older gfc is rejected, see DecryptLegacyCTR.
+func DecryptCTR(ciphertext Buffer, aesKey []byte, opts ...Option) (Buffer, error) {
+	return decryptBuffer(ModeAesCTR, ciphertext, aesKey, opts)
+}
 
-		if err != nil {
-			return nil, errors.Wrap(err, ErrReadCTR.Error())
-		}
+// DecryptLegacyCTR is like DecryptCTR, but also decrypts unauthenticated AES256-CTR output
+// written by older gfc, for which tampered ciphertext is NOT detected, and decrypts to corrupted plaintext.
+func DecryptLegacyCTR(ciphertext Buffer, aesKey []byte, opts ...Option) (Buffer, error) {
+	mode := ModeAesCTRLegacy
+	if hdr, err := ParseHeader(ciphertext.Bytes()); err == nil && hdr.Mode == ModeAesCTR {
+		mode = ModeAesCTR
 	}
 
-	return plaintext, nil
+	return decryptBuffer(mode, ciphertext, aesKey, append(opts[:len(opts):len(opts)], WithLegacyCTR(true)))
 }
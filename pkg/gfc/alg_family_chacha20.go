@@ -3,102 +3,99 @@ package gfc
 // This file provides (X)ChaCha20-Poly1305 encryption for gfc.
 
 import (
-	"bytes"
 	"crypto/cipher"
-	"crypto/rand"
 
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/chacha20poly1305"
 )
 
+func newCipherChaCha20(newCipherFunc func([]byte) (cipher.AEAD, error)) func([]byte) (cipher.AEAD, error) {
+	return func(key []byte) (cipher.AEAD, error) {
+		aead, err := newCipherFunc(key)
+		if err != nil {
+			return nil, errors.Wrap(ErrNewCipherXChaCha20Poly1305, err.Error())
+		}
+
+		return aead, nil
+	}
+}
+
 func EncryptFamilyChaCha20(
 	newCipherFunc func([]byte) (cipher.AEAD, error),
 	nonceSize int,
+	mode AlgoMode,
 	plaintext Buffer,
 	key []byte,
+	opts ...Option,
 ) (
 	Buffer,
 	error,
 ) {
-	key, salt, err := keySaltPBKDF2(key, nil)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to get key and salt with PBKDF2")
-	}
-
-	block, err := newCipherFunc(key)
-	if err != nil {
-		return nil, errors.Wrap(err, ErrNewCipherXChaCha20Poly1305.Error())
-	}
-
-	nonce := make([]byte, nonceSize)
-	rand.Read(nonce)
-
-	return formatOutputGfcSymm(
-		block.Seal(nil, nonce, plaintext.Bytes(), nil),
-		nonce,
-		salt,
-	)
+	return encryptBuffer(mode, plaintext, key, append(opts[:len(opts):len(opts)], withAEAD(aeadSpec{
+		newCipher: newCipherChaCha20(newCipherFunc),
+		nonceSize: nonceSize,
+		errOpen:   ErrOpenXChaCha20Poly1305,
+	})))
 }
 
 func DecryptFamilyChaCha20(
 	newCipherFunc func([]byte) (cipher.AEAD, error),
 	nonceSize int,
+	mode AlgoMode,
 	ciphertext Buffer,
 	key []byte,
+	opts ...Option,
 ) (
 	Buffer,
 	error,
 ) {
-	_, ciphertextBytes, key, nonce, err := decodeOutputGfcSymm(ciphertext, key, nonceSize)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to unmarshal gfc symmAEAD format")
-	}
-
-	block, err := newCipherFunc(key)
-	if err != nil {
-		return nil, errors.Wrap(err, ErrNewCipherXChaCha20Poly1305.Error())
-	}
-
-	plaintext, err := block.Open(nil, nonce, ciphertextBytes, nil)
-	if err != nil {
-		return nil, errors.Wrap(err, ErrOpenXChaCha20Poly1305.Error())
-	}
-
-	return bytes.NewBuffer(plaintext), nil
+	return decryptBuffer(mode, ciphertext, key, append(opts[:len(opts):len(opts)], withAEAD(aeadSpec{
+		newCipher: newCipherChaCha20(newCipherFunc),
+		nonceSize: nonceSize,
+		errOpen:   ErrOpenXChaCha20Poly1305,
+	})))
 }
 
-func EncryptXChaCha20Poly1305(plaintext Buffer, key []byte) (Buffer, error) {
+func EncryptXChaCha20Poly1305(plaintext Buffer, key []byte, opts ...Option) (Buffer, error) {
 	return EncryptFamilyChaCha20(
 		chacha20poly1305.NewX,
 		chacha20poly1305.NonceSizeX,
+		ModeXChaCha20Poly1305,
 		plaintext,
 		key,
+		opts...,
 	)
 }
 
-func DecryptXChaCha20Poly1305(ciphertext Buffer, key []byte) (Buffer, error) {
+func DecryptXChaCha20Poly1305(ciphertext Buffer, key []byte, opts ...Option) (Buffer, error) {
 	return DecryptFamilyChaCha20(
 		chacha20poly1305.NewX,
 		chacha20poly1305.NonceSizeX,
+		ModeXChaCha20Poly1305,
 		ciphertext,
 		key,
+		opts...,
 	)
 }
 
-func EncryptChaCha20Poly1305(plaintext Buffer, key []byte) (Buffer, error) {
+func EncryptChaCha20Poly1305(plaintext Buffer, key []byte, opts ...Option) (Buffer, error) {
 	return EncryptFamilyChaCha20(
 		chacha20poly1305.New,
 		chacha20poly1305.NonceSize,
+		ModeChaCha20Poly1305,
 		plaintext,
 		key,
+		opts...,
 	)
 }
 
-func DecryptChaCha20Poly1305(ciphertext Buffer, key []byte) (Buffer, error) {
+func DecryptChaCha20Poly1305(ciphertext Buffer, key []byte, opts ...Option) (Buffer, error) {
 	return DecryptFamilyChaCha20(
 		chacha20poly1305.New,
 		chacha20poly1305.NonceSize,
+		ModeChaCha20Poly1305,
 		ciphertext,
 		key,
+		opts...,
 	)
 }
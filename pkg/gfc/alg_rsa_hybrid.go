@@ -0,0 +1,96 @@
+package gfc
+
+// This file provides hybrid RSA encryption for gfc (see hybrid.go).
+// Only the random file key is encrypted with RSA-OEAP, so unlike
+// RSA256-OEAP, messages of any length can be encrypted.
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// labelRSAStanza is the OAEP label of RSA stanzas. The header cannot be used as label,
+// because the stanzas are part of the header, which is authenticated by the payload instead.
+var labelRSAStanza = []byte("gfc RSA-OAEP file key")
+
+type rsaRecipient struct {
+	pub *rsa.PublicKey
+}
+
+type rsaIdentity struct {
+	pri *rsa.PrivateKey
+}
+
+func (r rsaRecipient) wrap(fileKey []byte) (Stanza, error) {
+	wrapped, err := rsa.EncryptOAEP(sha512.New(), rand.Reader, r.pub, fileKey, labelRSAStanza)
+	if err != nil {
+		return Stanza{}, errors.Wrap(ErrEncryptRSA, err.Error())
+	}
+
+	return Stanza{Type: StanzaRSA, Body: wrapped}, nil
+}
+
+// unwrap cannot tell a stanza for another key from a tampered stanza,
+// so any stanza it cannot decrypt is reported as not matching.
+func (id rsaIdentity) unwrap(stanza Stanza) ([]byte, error) {
+	if stanza.Type != StanzaRSA || len(stanza.Body) != id.pri.Size() {
+		return nil, ErrStanzaNoMatch
+	}
+
+	fileKey, err := rsa.DecryptOAEP(sha512.New(), rand.Reader, id.pri, stanza.Body, labelRSAStanza)
+	if err != nil {
+		return nil, ErrStanzaNoMatch
+	}
+
+	return fileKey, nil
+}
+
+// RecipientRSA returns recipient for PEM-encoded RSA public key
+func RecipientRSA(pubKey []byte) (Recipient, error) {
+	pub, err := parsePubKeyRSA(pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return rsaRecipient{pub: pub}, nil
+}
+
+// IdentityRSA returns identity for PEM-encoded RSA private key
+func IdentityRSA(priKey []byte) (Identity, error) {
+	pri, err := parsePriKeyRSA(priKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return rsaIdentity{pri: pri}, nil
+}
+
+func newEncryptWriterRSAHybrid(w io.Writer, pubKey []byte, o *options) (io.WriteCloser, error) {
+	r, err := RecipientRSA(pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return newEncryptWriterHybrid(w, ModeRsaHybrid, []Recipient{r}, o)
+}
+
+func newDecryptReaderRSAHybrid(r io.Reader, hdr *Header, aad []byte, priKey []byte) (io.Reader, error) {
+	id, err := IdentityRSA(priKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDecryptReaderHybrid(r, hdr, aad, []Identity{id})
+}
+
+func EncryptRSAHybrid(plaintext Buffer, pubKey []byte, opts ...Option) (Buffer, error) {
+	return encryptBuffer(ModeRsaHybrid, plaintext, pubKey, opts)
+}
+
+func DecryptRSAHybrid(ciphertext Buffer, priKey []byte, opts ...Option) (Buffer, error) {
+	return decryptBuffer(ModeRsaHybrid, ciphertext, priKey, opts)
+}
@@ -1,6 +1,8 @@
 package gfc
 
 // This file provides RSA-OEAP encryption for gfc.
+// The serialized gfc header (followed by AAD if any, see aad.go)
+// is used as OAEP label, so that it is authenticated during decryption.
 
 import (
 	"bytes"
@@ -9,60 +11,145 @@ import (
 	"crypto/sha512"
 	"crypto/x509"
 	"encoding/pem"
+	"io"
 
 	"github.com/pkg/errors"
 )
 
-func EncryptRSA(plaintext Buffer, pubKey []byte) (Buffer, error) {
-	block, _ := pem.Decode([]byte(pubKey))
-
-	var pub *rsa.PublicKey
+func parsePubKeyRSA(pubKey []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(pubKey)
+	if block == nil {
+		return nil, errors.Wrap(ErrParsePubRSA, "no PEM block found")
+	}
 
 	// PKIX is PKCS1 with certificates/identity metadata
 	pubInterface, err := x509.ParsePKIXPublicKey(block.Bytes)
 	if err != nil {
 		// PKCS1 does not have certificates
-		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		pub, err := x509.ParsePKCS1PublicKey(block.Bytes)
 		if err != nil {
-			return nil, errors.Wrap(err, ErrParsePubRSA.Error())
+			return nil, errors.Wrap(ErrParsePubRSA, err.Error())
 		}
 
-	} else {
-		pub = pubInterface.(*rsa.PublicKey)
+		return pub, nil
+	}
+
+	pub, ok := pubInterface.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.Wrap(ErrParsePubRSA, "not an RSA public key")
 	}
 
-	var plaintextBytes []byte
-	switch plaintext := plaintext.(type) {
-	case *bytes.Buffer:
-		plaintextBytes = plaintext.Bytes()
+	return pub, nil
+}
+
+func parsePriKeyRSA(priKey []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(priKey)
+	if block == nil {
+		return nil, errors.Wrap(ErrParsePriRSA, "no PEM block found")
 	}
 
-	ciphertext, err := rsa.EncryptOAEP(sha512.New(), rand.Reader, pub, plaintextBytes, nil)
+	pri, err := x509.ParsePKCS1PrivateKey(block.Bytes)
 	if err != nil {
-		return nil, errors.Wrap(err, ErrEncryptRSA.Error())
+		// PKCS8 can hold any private key type
+		priInterface, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(ErrParsePriRSA, err.Error())
+		}
+
+		pri, ok := priInterface.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.Wrap(ErrParsePriRSA, "not an RSA private key")
+		}
+
+		return pri, nil
 	}
 
-	return bytes.NewBuffer(ciphertext), nil
+	return pri, nil
 }
 
-func DecryptRSA(ciphertext Buffer, priKey []byte) (Buffer, error) {
-	block, _ := pem.Decode([]byte(priKey))
+func newEncryptWriterRSA(w io.Writer, pubKey []byte, o *options) (io.WriteCloser, error) {
+	pub, err := parsePubKeyRSA(pubKey)
+	if err != nil {
+		return nil, err
+	}
 
-	pri, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	header, err := newHeader(ModeRsaOEAP, o).marshal()
 	if err != nil {
-		return nil, errors.Wrap(err, ErrParsePriRSA.Error())
+		return nil, errors.Wrap(err, "RSA256-OEAP encryption")
 	}
 
-	var ciphertextBytes []byte
-	switch ciphertext := ciphertext.(type) {
-	case *bytes.Buffer:
-		ciphertextBytes = ciphertext.Bytes()
+	if _, err := w.Write(header); err != nil {
+		return nil, errors.Wrap(err, "failed to write header")
+	}
+
+	return &rsaWriter{w: w, pub: pub, label: associatedData(header, o)}, nil
+}
+
+func newDecryptReaderRSA(r io.Reader, label []byte, priKey []byte) (io.Reader, error) {
+	pri, err := parsePriKeyRSA(priKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read ciphertext")
 	}
 
-	plaintext, err := rsa.DecryptOAEP(sha512.New(), rand.Reader, pri, ciphertextBytes, nil)
+	plaintext, err := rsa.DecryptOAEP(sha512.New(), rand.Reader, pri, ciphertext, label)
 	if err != nil {
-		return nil, errors.Wrap(err, ErrDecryptRSA.Error())
+		return nil, errors.Wrap(ErrDecryptRSA, err.Error())
 	}
 
-	return bytes.NewBuffer(plaintext), nil
+	return bytes.NewReader(plaintext), nil
+}
+
+// Output written before gfc header was introduced has no label
+func decryptLegacyRSA(ciphertext []byte, priKey []byte) (io.Reader, error) {
+	return newDecryptReaderRSA(bytes.NewReader(ciphertext), nil, priKey)
+}
+
+// rsaWriter buffers plaintext, and encrypts it on Close,
+// since RSA-OEAP can only encrypt a short message in one shot.
+type rsaWriter struct {
+	w      io.Writer
+	pub    *rsa.PublicKey
+	label  []byte
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (r *rsaWriter) Write(p []byte) (int, error) {
+	if r.closed {
+		return 0, ErrStreamClosed
+	}
+
+	return r.buf.Write(p)
+}
+
+func (r *rsaWriter) Close() error {
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+
+	ciphertext, err := rsa.EncryptOAEP(sha512.New(), rand.Reader, r.pub, r.buf.Bytes(), r.label)
+	if err != nil {
+		return errors.Wrap(ErrEncryptRSA, err.Error())
+	}
+
+	if _, err := r.w.Write(ciphertext); err != nil {
+		return errors.Wrap(err, "failed to write ciphertext")
+	}
+
+	return nil
+}
+
+func EncryptRSA(plaintext Buffer, pubKey []byte, opts ...Option) (Buffer, error) {
+	return encryptBuffer(ModeRsaOEAP, plaintext, pubKey, opts)
+}
+
+func DecryptRSA(ciphertext Buffer, priKey []byte, opts ...Option) (Buffer, error) {
+	return decryptBuffer(ModeRsaOEAP, ciphertext, priKey, opts)
 }
@@ -0,0 +1,262 @@
+package gfc
+
+// This file provides X25519 public-key encryption for gfc (see hybrid.go).
+// For each recipient, an ephemeral X25519 key is generated, and the shared secret
+// from ephemeral-static ECDH is expanded with HKDF-SHA256 into a wrap key,
+// which encrypts the file key with ChaCha20-Poly1305. The stanza body is:
+//
+//	<Ephemeral public key (32 bytes)> <Wrapped file key (48 bytes)>
+//
+// Keys are text-encoded as prefix followed by unpadded base32, e.g.
+// GFC-X25519-PUB-... for public keys and GFC-X25519-SEC-... for private keys.
+// Recipient and identity lists contain one key per line, and lines starting with '#' are ignored.
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	PrefixX25519PublicKey  = "GFC-X25519-PUB-"
+	PrefixX25519PrivateKey = "GFC-X25519-SEC-"
+
+	lenX25519Key          = curve25519.ScalarSize
+	lenTextKey            = 32 // Length of decoded X25519 and Ed25519 text keys
+	lenX25519StanzaBody   = lenX25519Key + lenWrappedFileKey
+	infoX25519WrapKeyHKDF = "gfc X25519 file key"
+)
+
+// encodingTextKey encodes text keys of X25519 and Ed25519
+var encodingTextKey = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type x25519Recipient struct {
+	pub []byte
+}
+
+type x25519Identity struct {
+	pri []byte
+	pub []byte
+}
+
+// GenerateX25519 generates a new X25519 keypair, and returns the text-encoded public and private keys
+func GenerateX25519() (string, string, error) {
+	pri := make([]byte, lenX25519Key)
+	if _, err := rand.Read(pri); err != nil {
+		return "", "", errors.Wrapf(ErrRandom, "failed to read random X25519 private key: %s", err)
+	}
+
+	pub, err := curve25519.X25519(pri, curve25519.Basepoint)
+	if err != nil {
+		return "", "", errors.Wrap(ErrX25519, err.Error())
+	}
+
+	return encodeTextKey(PrefixX25519PublicKey, pub), encodeTextKey(PrefixX25519PrivateKey, pri), nil
+}
+
+// PublicKeyX25519 returns the text-encoded public key of text-encoded private key
+func PublicKeyX25519(privateKey string) (string, error) {
+	pri, err := decodeX25519Key(PrefixX25519PrivateKey, privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	pub, err := curve25519.X25519(pri, curve25519.Basepoint)
+	if err != nil {
+		return "", errors.Wrap(ErrX25519, err.Error())
+	}
+
+	return encodeTextKey(PrefixX25519PublicKey, pub), nil
+}
+
+func encodeTextKey(prefix string, key []byte) string {
+	return prefix + encodingTextKey.EncodeToString(key)
+}
+
+func decodeX25519Key(prefix, encoded string) ([]byte, error) {
+	return decodeTextKey(prefix, encoded, ErrParseX25519)
+}
+
+// parseKeysX25519 decodes a list of text-encoded keys with prefix, one per line
+func parseKeysX25519(prefix string, list []byte) ([][]byte, error) {
+	return parseTextKeys(prefix, list, ErrParseX25519)
+}
+
+// decodeTextKey decodes 32-byte text-encoded key with prefix, and reports errors as errParse
+func decodeTextKey(prefix, encoded string, errParse Error) ([]byte, error) {
+	encoded = strings.ToUpper(strings.TrimSpace(encoded))
+	if !strings.HasPrefix(encoded, prefix) {
+		return nil, errors.Wrapf(errParse, "missing key prefix %s", prefix)
+	}
+
+	key, err := encodingTextKey.DecodeString(strings.TrimPrefix(encoded, prefix))
+	if err != nil {
+		return nil, errors.Wrapf(errParse, "bad base32: %s", err.Error())
+	}
+
+	if len(key) != lenTextKey {
+		return nil, errors.Wrapf(errParse, "bad key length %d", len(key))
+	}
+
+	return key, nil
+}
+
+// parseTextKeys decodes a list of text-encoded keys with prefix, one per line
+func parseTextKeys(prefix string, list []byte, errParse Error) ([][]byte, error) {
+	var keys [][]byte
+	for _, line := range strings.Split(string(list), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, err := decodeTextKey(prefix, line, errParse)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.Wrap(errParse, "no keys found")
+	}
+
+	return keys, nil
+}
+
+// wrapKeyX25519 derives key for wrapping file key from X25519 shared secret
+func wrapKeyX25519(shared, ephemeralPub, recipientPub []byte) ([]byte, error) {
+	salt := append(append([]byte{}, ephemeralPub...), recipientPub...)
+	kdf := hkdf.New(sha256.New, shared, salt, []byte(infoX25519WrapKeyHKDF))
+
+	wrapKey := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(kdf, wrapKey); err != nil {
+		return nil, errors.Wrap(err, "HKDF failed")
+	}
+
+	return wrapKey, nil
+}
+
+func (r x25519Recipient) wrap(fileKey []byte) (Stanza, error) {
+	ephemeral := make([]byte, lenX25519Key)
+	if _, err := rand.Read(ephemeral); err != nil {
+		return Stanza{}, errors.Wrapf(ErrRandom, "failed to read random X25519 ephemeral key: %s", err)
+	}
+
+	ephemeralPub, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return Stanza{}, errors.Wrap(ErrX25519, err.Error())
+	}
+
+	// X25519 rejects low order public keys, which result in all-zero shared secret
+	shared, err := curve25519.X25519(ephemeral, r.pub)
+	if err != nil {
+		return Stanza{}, errors.Wrap(ErrX25519, err.Error())
+	}
+
+	wrapKey, err := wrapKeyX25519(shared, ephemeralPub, r.pub)
+	if err != nil {
+		return Stanza{}, err
+	}
+
+	wrapped, err := sealFileKey(wrapKey, fileKey)
+	if err != nil {
+		return Stanza{}, err
+	}
+
+	return Stanza{
+		Type: StanzaX25519,
+		Body: append(ephemeralPub, wrapped...),
+	}, nil
+}
+
+func (id x25519Identity) unwrap(stanza Stanza) ([]byte, error) {
+	if stanza.Type != StanzaX25519 || len(stanza.Body) != lenX25519StanzaBody {
+		return nil, ErrStanzaNoMatch
+	}
+
+	ephemeralPub, wrapped := stanza.Body[:lenX25519Key], stanza.Body[lenX25519Key:]
+
+	shared, err := curve25519.X25519(id.pri, ephemeralPub)
+	if err != nil {
+		return nil, ErrStanzaNoMatch
+	}
+
+	wrapKey, err := wrapKeyX25519(shared, ephemeralPub, id.pub)
+	if err != nil {
+		return nil, err
+	}
+
+	return openFileKey(wrapKey, wrapped)
+}
+
+// RecipientsX25519 returns recipients for a list of text-encoded public keys (one per line)
+func RecipientsX25519(publicKeys []byte) ([]Recipient, error) {
+	pubs, err := parseKeysX25519(PrefixX25519PublicKey, publicKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := make([]Recipient, len(pubs))
+	for i, pub := range pubs {
+		recipients[i] = x25519Recipient{pub: pub}
+	}
+
+	return recipients, nil
+}
+
+// IdentitiesX25519 returns identities for a list of text-encoded private keys (one per line)
+func IdentitiesX25519(privateKeys []byte) ([]Identity, error) {
+	pris, err := parseKeysX25519(PrefixX25519PrivateKey, privateKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	identities := make([]Identity, len(pris))
+	for i, pri := range pris {
+		pub, err := curve25519.X25519(pri, curve25519.Basepoint)
+		if err != nil {
+			return nil, errors.Wrap(ErrX25519, err.Error())
+		}
+
+		identities[i] = x25519Identity{pri: pri, pub: pub}
+	}
+
+	return identities, nil
+}
+
+func newEncryptWriterX25519(w io.Writer, publicKeys []byte, o *options) (io.WriteCloser, error) {
+	recipients, err := RecipientsX25519(publicKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return newEncryptWriterHybrid(w, ModeX25519, recipients, o)
+}
+
+func newDecryptReaderX25519(r io.Reader, hdr *Header, aad []byte, privateKeys []byte) (io.Reader, error) {
+	identities, err := IdentitiesX25519(privateKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDecryptReaderHybrid(r, hdr, aad, identities)
+}
+
+// EncryptX25519 encrypts plaintext to recipients, a list of text-encoded X25519 public keys (one per line)
+func EncryptX25519(plaintext Buffer, recipients []byte, opts ...Option) (Buffer, error) {
+	return encryptBuffer(ModeX25519, plaintext, recipients, opts)
+}
+
+// DecryptX25519 decrypts ciphertext with identities, a list of text-encoded X25519 private keys (one per line)
+func DecryptX25519(ciphertext Buffer, identities []byte, opts ...Option) (Buffer, error) {
+	return decryptBuffer(ModeX25519, ciphertext, identities, opts)
+}
@@ -0,0 +1,317 @@
+package gfc
+
+// This file provides directory archiving for gfc, so that a directory
+// can be encrypted without writing an unencrypted tarball to disk.
+// The directory is archived as tar stream on the fly, with paths relative
+// to the directory. Only directories, regular files, and symlinks are archived.
+//
+// Extraction rejects entries with absolute paths or paths escaping the
+// extraction directory, symlinks pointing outside of it (also through other
+// symlinks, see checkLinkTarget), and never writes through symlinks or over existing files.
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxArchiveLinks limits symlinks followed when resolving symlink target, like ELOOP of the kernel
+const maxArchiveLinks = 255
+
+// NewArchiveReader returns a reader of tar archive of directory dir.
+// The archive is generated as it is read, so dir is never archived to disk or memory.
+// Close stops archiving.
+func NewArchiveReader(dir string) io.ReadCloser {
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(writeArchive(pw, dir))
+	}()
+
+	return pr
+}
+
+func writeArchive(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return errors.Wrapf(err, "bad path %s", name)
+		}
+
+		if rel == "." {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return errors.Wrapf(err, "failed to stat %s", name)
+		}
+
+		var link string
+		switch {
+		case info.Mode().IsRegular(), info.IsDir():
+
+		case info.Mode()&fs.ModeSymlink != 0:
+			if link, err = os.Readlink(name); err != nil {
+				return errors.Wrapf(err, "failed to read symlink %s", name)
+			}
+
+		default:
+			return errors.Wrapf(ErrArchiveEntry, "%s is not a directory, regular file, or symlink", name)
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create tar header for %s", name)
+		}
+
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return errors.Wrapf(err, "failed to write tar header for %s", name)
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(name)
+		if err != nil {
+			return errors.Wrapf(err, "failed to open %s", name)
+		}
+
+		defer f.Close()
+
+		if _, err := io.Copy(tw, f); err != nil {
+			return errors.Wrapf(err, "failed to archive %s", name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// ExtractArchive extracts tar archive from r into directory dir, which is created if it does not exist.
+// Unsafe entries fail with ErrArchiveUnsafePath, and existing files are never overwritten.
+func ExtractArchive(r io.Reader, dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return errors.Wrapf(err, "failed to create directory %s", dir)
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "failed to read archive")
+		}
+
+		if err := extractEntry(tr, hdr, dir); err != nil {
+			return err
+		}
+	}
+}
+
+func extractEntry(r io.Reader, hdr *tar.Header, dir string) error {
+	name, err := archivePath(hdr.Name)
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(dir, filepath.FromSlash(name))
+
+	// Never write through symlinks, which could point anywhere
+	if err := checkNoSymlink(dir, name); err != nil {
+		return err
+	}
+
+	perm := hdr.FileInfo().Mode().Perm()
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err := os.MkdirAll(target, perm|0o700); err != nil {
+			return errors.Wrapf(err, "failed to create directory %s", target)
+		}
+
+		return nil
+
+	case tar.TypeReg, tar.TypeRegA: //nolint:staticcheck
+		if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+			return errors.Wrapf(err, "failed to create directory for %s", target)
+		}
+
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create file %s", target)
+		}
+
+		defer f.Close()
+
+		if _, err := io.Copy(f, r); err != nil {
+			return errors.Wrapf(err, "failed to extract %s", target)
+		}
+
+		return nil
+
+	case tar.TypeSymlink:
+		if path.IsAbs(hdr.Linkname) || filepath.IsAbs(hdr.Linkname) {
+			return errors.Wrapf(ErrArchiveUnsafePath, "symlink %s points to absolute path %s", hdr.Name, hdr.Linkname)
+		}
+
+		if err := checkLinkTarget(dir, name, hdr.Linkname); err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+			return errors.Wrapf(err, "failed to create directory for %s", target)
+		}
+
+		if err := os.Symlink(hdr.Linkname, target); err != nil {
+			return errors.Wrapf(err, "failed to create symlink %s", target)
+		}
+
+		return nil
+	}
+
+	return errors.Wrapf(ErrArchiveEntry, "entry %s has unsupported type %c", hdr.Name, hdr.Typeflag)
+}
+
+// archivePath validates and cleans archive entry name, which must be relative and must not escape
+func archivePath(name string) (string, error) {
+	// Backslash is a path separator on Windows
+	if name == "" || path.IsAbs(name) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" ||
+		(filepath.Separator == '\\' && strings.Contains(name, `\`)) {
+		return "", errors.Wrapf(ErrArchiveUnsafePath, "bad entry name %q", name)
+	}
+
+	clean := path.Clean(name)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", errors.Wrapf(ErrArchiveUnsafePath, "entry %q escapes directory", name)
+	}
+
+	return clean, nil
+}
+
+// checkNoSymlink returns ErrArchiveUnsafePath if any existing path component of name under dir is a symlink
+func checkNoSymlink(dir, name string) error {
+	if name == "." {
+		return nil
+	}
+
+	current := dir
+	for _, component := range strings.Split(name, "/") {
+		current = filepath.Join(current, component)
+
+		info, err := os.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "failed to stat %s", current)
+		}
+
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return errors.Wrapf(ErrArchiveUnsafePath, "path %s goes through symlink %s", name, current)
+		}
+	}
+
+	return nil
+}
+
+// checkLinkTarget returns ErrArchiveUnsafePath if symlink name (relative to dir) pointing to linkname
+// may resolve outside of dir. The target is resolved through symlinks already extracted under dir,
+// since e.g. 'l1 -> l2/..' escapes if 'l2 -> .', even though it looks safe. A component which does not
+// exist yet may later be extracted as a symlink to anywhere inside dir, so '..' must not follow it.
+func checkLinkTarget(dir, name, linkname string) error {
+	unsafe := func(reason string) error {
+		return errors.Wrapf(ErrArchiveUnsafePath, "symlink %s -> %s %s", name, linkname, reason)
+	}
+
+	// Symlink target is relative to the directory containing the symlink
+	var resolved []string
+	if parent := path.Dir(name); parent != "." {
+		resolved = strings.Split(parent, "/")
+	}
+
+	pending := strings.Split(linkname, "/")
+	for links := 0; len(pending) > 0; {
+		component := pending[0]
+		pending = pending[1:]
+
+		switch component {
+		case "", ".":
+			continue
+
+		case "..":
+			if len(resolved) == 0 {
+				return unsafe("points outside of directory")
+			}
+
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+
+		resolved = append(resolved, component)
+		current := filepath.Join(dir, filepath.FromSlash(path.Join(resolved...)))
+
+		info, err := os.Lstat(current)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			for _, next := range pending {
+				if next == ".." {
+					return unsafe("goes back through a path not yet extracted")
+				}
+			}
+
+			return nil
+
+		case err != nil:
+			return errors.Wrapf(err, "failed to stat %s", current)
+
+		case info.Mode()&fs.ModeSymlink == 0:
+			continue
+		}
+
+		if links++; links > maxArchiveLinks {
+			return unsafe("goes through too many symlinks")
+		}
+
+		link, err := os.Readlink(current)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read symlink %s", current)
+		}
+
+		if path.IsAbs(link) || filepath.IsAbs(link) {
+			return unsafe("goes through symlink to absolute path")
+		}
+
+		// Continue from the target of the symlink, relative to its directory
+		resolved = resolved[:len(resolved)-1]
+		pending = append(strings.Split(filepath.ToSlash(link), "/"), pending...)
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package gfc
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchive(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]string{
+		"foo.txt":         "this is foo",
+		"sub/bar.txt":     "this is bar",
+		"sub/deep/baz.md": "this is baz",
+	}
+
+	for name, content := range files {
+		p := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Symlink("sub/bar.txt", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	key := make([]byte, aes256BitKeyFileLen)
+
+	ciphertext := new(bytes.Buffer)
+	w, err := NewEncryptWriter(ciphertext, ModeAesGCM, key, WithArchive(true))
+	if err != nil {
+		t.Fatalf("error creating encrypt writer: %s", err.Error())
+	}
+
+	archive := NewArchiveReader(src)
+	defer archive.Close()
+
+	if _, err := io.Copy(w, archive); err != nil {
+		t.Fatalf("error archiving: %s", err.Error())
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("error closing encrypt writer: %s", err.Error())
+	}
+
+	hdr, r, err := PeekHeader(ciphertext)
+	if err != nil {
+		t.Fatalf("error peeking header: %s", err.Error())
+	}
+
+	if hdr.Flags&FlagArchive == 0 {
+		t.Fatal("missing archive flag")
+	}
+
+	plaintext, err := NewDecryptReader(r, key)
+	if err != nil {
+		t.Fatalf("error creating decrypt reader: %s", err.Error())
+	}
+
+	dst := filepath.Join(t.TempDir(), "out")
+	if err := ExtractArchive(plaintext, dst); err != nil {
+		t.Fatalf("error extracting: %s", err.Error())
+	}
+
+	for name, content := range files {
+		b, err := os.ReadFile(filepath.Join(dst, name))
+		if err != nil || string(b) != content {
+			t.Fatalf("unexpected content of %s: %q (%v)", name, b, err)
+		}
+	}
+
+	if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != "sub/bar.txt" {
+		t.Fatalf("unexpected symlink %s (%v)", link, err)
+	}
+
+	// Existing files are never overwritten
+	if err := ExtractArchive(NewArchiveReader(src), dst); err == nil {
+		t.Fatal("expecting error extracting over existing files")
+	}
+}
+
+func TestExtractArchiveUnsafe(t *testing.T) {
+	type entry struct {
+		name     string
+		typeflag byte
+		link     string
+	}
+
+	unsafe := map[string][]entry{
+		"parent path":    {{name: "../evil", typeflag: tar.TypeReg}},
+		"nested parent":  {{name: "sub/../../evil", typeflag: tar.TypeReg}},
+		"absolute path":  {{name: "/tmp/evil", typeflag: tar.TypeReg}},
+		"absolute link":  {{name: "link", typeflag: tar.TypeSymlink, link: "/etc/passwd"}},
+		"escaping link":  {{name: "sub/link", typeflag: tar.TypeSymlink, link: "../../evil"}},
+		"through link":   {{name: "link", typeflag: tar.TypeSymlink, link: "sub"}, {name: "link/evil", typeflag: tar.TypeReg}},
+		"link over link": {{name: "link", typeflag: tar.TypeSymlink, link: "."}, {name: "link", typeflag: tar.TypeSymlink, link: "."}},
+		"chained links":  {{name: "l2", typeflag: tar.TypeSymlink, link: "."}, {name: "l1", typeflag: tar.TypeSymlink, link: "l2/.."}},
+		"nested chain":   {{name: "sub/l2", typeflag: tar.TypeSymlink, link: ".."}, {name: "l1", typeflag: tar.TypeSymlink, link: "sub/l2/../.."}},
+		"link later":     {{name: "l1", typeflag: tar.TypeSymlink, link: "l2/.."}, {name: "l2", typeflag: tar.TypeSymlink, link: "."}},
+	}
+
+	for name, entries := range unsafe {
+		archive := new(bytes.Buffer)
+		tw := tar.NewWriter(archive)
+
+		for _, e := range entries {
+			if err := tw.WriteHeader(&tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.link, Mode: 0o600}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		dir := filepath.Join(t.TempDir(), "out")
+		if err := ExtractArchive(archive, dir); !errors.Is(err, ErrArchiveUnsafePath) {
+			t.Fatalf("%s: expecting ErrArchiveUnsafePath, got %v", name, err)
+		}
+	}
+
+	// Links through other links are fine if they stay inside
+	archive := new(bytes.Buffer)
+	tw := tar.NewWriter(archive)
+	for _, e := range []entry{
+		{name: "sub/", typeflag: tar.TypeDir},
+		{name: "l2", typeflag: tar.TypeSymlink, link: "sub"},
+		{name: "l1", typeflag: tar.TypeSymlink, link: "l2/.."},
+		{name: "sub/l3", typeflag: tar.TypeSymlink, link: "../l1/sub"},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.link, Mode: 0o700}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ExtractArchive(archive, filepath.Join(t.TempDir(), "out")); err != nil {
+		t.Fatalf("safe links: unexpected error: %s", err.Error())
+	}
+}
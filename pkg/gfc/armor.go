@@ -0,0 +1,311 @@
+package gfc
+
+// This file provides ASCII armor, a text encoding of gfc output which survives
+// mail clients, chat tools, and tickets. It is similar to OpenPGP armor:
+//
+//	-----BEGIN GFC ENCRYPTED MESSAGE-----
+//	Version: 1
+//
+//	<Base64 of output, wrapped at 64 columns>
+//	=<Base64 of CRC-24 of output>
+//	-----END GFC ENCRYPTED MESSAGE-----
+//
+// Decoding skips any text before the BEGIN line, and ignores trailing whitespace
+// (including CR of CRLF line endings) and blank lines inside the armor.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	armorBegin   = "-----BEGIN GFC ENCRYPTED MESSAGE-----"
+	armorEnd     = "-----END GFC ENCRYPTED MESSAGE-----"
+	armorVersion = "1"
+	armorColumns = 64
+
+	// maxArmorLine limits armor lines, so that garbage input is not read to memory as one line
+	maxArmorLine = 4096
+
+	// CRC-24 of RFC 4880
+	crc24Init = 0xb704ce
+	crc24Poly = 0x1864cfb
+	crc24Mask = 0xffffff
+)
+
+func crc24(crc uint32, b []byte) uint32 {
+	for _, c := range b {
+		crc ^= uint32(c) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= crc24Poly
+			}
+		}
+	}
+
+	return crc & crc24Mask
+}
+
+func marshalCRC24(crc uint32) string {
+	return base64.StdEncoding.EncodeToString([]byte{byte(crc >> 16), byte(crc >> 8), byte(crc)})
+}
+
+// NewArmorWriter returns a writer which armors data written to it, and writes it to w.
+// Callers must call Close to write the checksum and the END line. Close does not close w.
+func NewArmorWriter(w io.Writer) io.WriteCloser {
+	lines := &lineWriter{w: w}
+	return &armorWriter{
+		lines:   lines,
+		encoder: base64.NewEncoder(base64.StdEncoding, lines),
+		head:    armorBegin + "\nVersion: " + armorVersion + "\n\n",
+		crc:     crc24Init,
+	}
+}
+
+type armorWriter struct {
+	lines   *lineWriter
+	encoder io.WriteCloser
+	head    string // Written before the first body line
+	crc     uint32
+}
+
+func (a *armorWriter) writeHead() error {
+	if a.head == "" {
+		return nil
+	}
+
+	if _, err := io.WriteString(a.lines.w, a.head); err != nil {
+		return errors.Wrap(err, "failed to write armor header")
+	}
+
+	a.head = ""
+
+	return nil
+}
+
+func (a *armorWriter) Write(b []byte) (int, error) {
+	if err := a.writeHead(); err != nil {
+		return 0, err
+	}
+
+	a.crc = crc24(a.crc, b)
+
+	return a.encoder.Write(b)
+}
+
+func (a *armorWriter) Close() error {
+	if err := a.writeHead(); err != nil {
+		return err
+	}
+
+	if err := a.encoder.Close(); err != nil {
+		return errors.Wrap(err, "failed to flush armor body")
+	}
+
+	tail := "=" + marshalCRC24(a.crc) + "\n" + armorEnd + "\n"
+	if a.lines.col != 0 {
+		tail = "\n" + tail
+	}
+
+	if _, err := io.WriteString(a.lines.w, tail); err != nil {
+		return errors.Wrap(err, "failed to write armor checksum")
+	}
+
+	return nil
+}
+
+// lineWriter writes to w, breaking lines at armorColumns
+type lineWriter struct {
+	w   io.Writer
+	col int
+}
+
+func (l *lineWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		n := armorColumns - l.col
+		if n > len(b) {
+			n = len(b)
+		}
+
+		if _, err := l.w.Write(b[:n]); err != nil {
+			return written, err
+		}
+
+		written += n
+		l.col += n
+		b = b[n:]
+
+		if l.col == armorColumns {
+			if _, err := l.w.Write([]byte{'\n'}); err != nil {
+				return written, err
+			}
+
+			l.col = 0
+		}
+	}
+
+	return written, nil
+}
+
+// NewArmorReader reads armor from r up to its body, and returns a reader which reads
+// the dearmored body, and the armor headers. The checksum is verified when the body is read to EOF.
+func NewArmorReader(r io.Reader) (io.Reader, map[string]string, error) {
+	br := bufio.NewReaderSize(r, maxArmorLine)
+
+	// Skip surrounding text before armor
+	for {
+		line, err := readArmorLine(br, true)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, nil, errors.Wrap(ErrEncoding, "missing armor BEGIN line")
+			}
+
+			return nil, nil, err
+		}
+
+		if line == armorBegin {
+			break
+		}
+	}
+
+	// Headers end with a blank line, or with the first line which is not a header.
+	// Base64 never contains ':', so body lines are never mistaken for headers.
+	headers := make(map[string]string)
+	var first string
+	for {
+		line, err := readArmorLine(br, false)
+		if err != nil {
+			return nil, nil, wrapArmorEOF(err)
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			first = line
+			break
+		}
+
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return &armorReader{br: br, next: first, crc: crc24Init}, headers, nil
+}
+
+type armorReader struct {
+	br      *bufio.Reader
+	next    string // Body line read while reading headers
+	pending []byte // Decoded body not yet read by caller
+	crc     uint32
+	done    bool
+}
+
+func (a *armorReader) Read(b []byte) (int, error) {
+	for len(a.pending) == 0 {
+		if a.done {
+			return 0, io.EOF
+		}
+
+		if err := a.readLine(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(b, a.pending)
+	a.pending = a.pending[n:]
+
+	return n, nil
+}
+
+// readLine reads and decodes the next body line, or verifies the checksum line
+func (a *armorReader) readLine() error {
+	line := a.next
+	a.next = ""
+
+	if line == "" {
+		var err error
+		if line, err = readArmorLine(a.br, false); err != nil {
+			return wrapArmorEOF(err)
+		}
+	}
+
+	switch {
+	case line == "":
+		return nil
+
+	case line == armorEnd:
+		return errors.Wrap(ErrEncoding, "missing armor checksum")
+
+	case strings.HasPrefix(line, "="):
+		if line[1:] != marshalCRC24(a.crc) {
+			return errors.Wrap(ErrEncoding, "armor checksum mismatch")
+		}
+
+		for {
+			end, err := readArmorLine(a.br, false)
+			if err != nil {
+				return wrapArmorEOF(err)
+			}
+
+			if end == armorEnd {
+				break
+			}
+
+			if end != "" {
+				return errors.Wrap(ErrEncoding, "unexpected armor line after checksum")
+			}
+		}
+
+		a.done = true
+
+		return nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(line)
+	if err != nil {
+		return errors.Wrapf(ErrEncoding, "bad armor line: %s", err)
+	}
+
+	a.crc = crc24(a.crc, decoded)
+	a.pending = decoded
+
+	return nil
+}
+
+// readArmorLine reads a line from br without trailing whitespace. Long lines are
+// skipped if skipLong is true (e.g. surrounding text), or are an error otherwise.
+func readArmorLine(br *bufio.Reader, skipLong bool) (string, error) {
+	line, isPrefix, err := br.ReadLine()
+	if err != nil {
+		return "", err
+	}
+
+	if !isPrefix {
+		return string(bytes.TrimRight(line, " \t\r")), nil
+	}
+
+	if !skipLong {
+		return "", errors.Wrapf(ErrEncoding, "armor line longer than %d bytes", maxArmorLine)
+	}
+
+	for isPrefix {
+		if _, isPrefix, err = br.ReadLine(); err != nil {
+			return "", err
+		}
+	}
+
+	return "", nil
+}
+
+func wrapArmorEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return errors.Wrap(ErrEncoding, "missing armor END line")
+	}
+
+	return errors.Wrap(err, "failed to read armor")
+}
@@ -0,0 +1,89 @@
+package gfc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func armor(t *testing.T, b []byte) string {
+	t.Helper()
+
+	armored := new(bytes.Buffer)
+	w := NewArmorWriter(armored)
+
+	if _, err := w.Write(b); err != nil {
+		t.Fatalf("error armoring: %s", err.Error())
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("error closing armor writer: %s", err.Error())
+	}
+
+	return armored.String()
+}
+
+func TestArmor(t *testing.T) {
+	for _, size := range []int{0, 1, 47, 48, 49, 4096} {
+		b := bytes.Repeat([]byte{0xa5}, size)
+		armored := armor(t, b)
+
+		lines := strings.Split(strings.TrimSuffix(armored, "\n"), "\n")
+		if lines[0] != armorBegin || lines[len(lines)-1] != armorEnd {
+			t.Fatalf("size %d: missing BEGIN or END line:\n%s", size, armored)
+		}
+
+		for _, line := range lines {
+			if len(line) > armorColumns {
+				t.Fatalf("size %d: line longer than %d columns: %s", size, armorColumns, line)
+			}
+		}
+
+		// Surrounding text, CRLF line endings, and headers added by other tools
+		pasted := "Hi, here is the secret:\r\n\r\n" + strings.ReplaceAll(armored, "\n", "\r\n") + "\r\nThanks\r\n"
+		pasted = strings.Replace(pasted, "Version: ", "Comment: ticket 42\r\nVersion: ", 1)
+
+		r, headers, err := NewArmorReader(strings.NewReader(pasted))
+		if err != nil {
+			t.Fatalf("size %d: error reading armor: %s", size, err.Error())
+		}
+
+		if headers["Version"] != armorVersion || headers["Comment"] != "ticket 42" {
+			t.Fatalf("size %d: unexpected headers %v", size, headers)
+		}
+
+		dearmored, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("size %d: error dearmoring: %s", size, err.Error())
+		}
+
+		if !bytes.Equal(dearmored, b) {
+			t.Fatalf("size %d: output does not match", size)
+		}
+	}
+}
+
+func TestArmorBad(t *testing.T) {
+	armored := armor(t, []byte("this is my ciphertext"))
+
+	tests := map[string]string{
+		"missing BEGIN":    strings.Replace(armored, armorBegin, "", 1),
+		"missing END":      strings.Replace(armored, armorEnd, "", 1),
+		"missing checksum": armored[:strings.Index(armored, "\n=")+1] + armorEnd + "\n",
+		"bad checksum":     strings.Replace(armored, "dGhp", "dGhQ", 1),
+		"bad body":         strings.Replace(armored, "dGhp", "d!hp", 1),
+	}
+
+	for name, input := range tests {
+		r, _, err := NewArmorReader(strings.NewReader(input))
+		if err == nil {
+			_, err = io.ReadAll(r)
+		}
+
+		if !errors.Is(err, ErrEncoding) {
+			t.Fatalf("%s: expecting ErrEncoding, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package gfc
+
+// This file provides base-58 encoding with the Bitcoin alphabet, which has no
+// punctuation and no look-alike characters (0, O, I, and l). Unlike other encodings,
+// base-58 treats the whole input as one big number, so its cost grows quadratically
+// with input length, and it cannot be streamed. It is only meant for short ciphertexts,
+// e.g. tokens, and input longer than maxBase58Len is rejected.
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	alphabetBase58 = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+	// maxBase58Len limits decoded length of base-58 data
+	maxBase58Len = 64 << 10
+
+	// Base-58 digits are converted 10 at a time, since 58^10 fits in uint64
+	digitsBase58Word = 10
+)
+
+var bigBase58Word = new(big.Int).Exp(big.NewInt(58), big.NewInt(digitsBase58Word), nil)
+
+func encodeBase58(b []byte) ([]byte, error) {
+	if len(b) > maxBase58Len {
+		return nil, errors.Wrapf(ErrEncoding, "input too long for base58 (%d bytes, max %d)", len(b), maxBase58Len)
+	}
+
+	zeros := 0
+	for zeros < len(b) && b[zeros] == 0 {
+		zeros++
+	}
+
+	// Digits are collected least significant first
+	var digits []byte
+	n := new(big.Int).SetBytes(b[zeros:])
+	word := new(big.Int)
+	for n.Sign() > 0 {
+		n.QuoRem(n, bigBase58Word, word)
+
+		w := word.Uint64()
+		for i := 0; i < digitsBase58Word; i++ {
+			digits = append(digits, alphabetBase58[w%58])
+			w /= 58
+		}
+	}
+
+	// Drop leading zero digits of the last word
+	for len(digits) > 0 && digits[len(digits)-1] == alphabetBase58[0] {
+		digits = digits[:len(digits)-1]
+	}
+
+	out := make([]byte, 0, zeros+len(digits))
+	out = append(out, bytes.Repeat([]byte{alphabetBase58[0]}, zeros)...)
+	for i := len(digits) - 1; i >= 0; i-- {
+		out = append(out, digits[i])
+	}
+
+	return out, nil
+}
+
+func decodeBase58(s []byte) ([]byte, error) {
+	s = bytes.TrimRight(s, "\r\n")
+
+	zeros := 0
+	for zeros < len(s) && s[zeros] == alphabetBase58[0] {
+		zeros++
+	}
+
+	n := new(big.Int)
+	word := new(big.Int)
+	for i := zeros; i < len(s); i += digitsBase58Word {
+		end := i + digitsBase58Word
+		if end > len(s) {
+			end = len(s)
+		}
+
+		var w, scale uint64 = 0, 1
+		for _, c := range s[i:end] {
+			digit := strings.IndexByte(alphabetBase58, c)
+			if digit < 0 {
+				return nil, errors.Wrapf(ErrEncoding, "bad base58 character %q", c)
+			}
+
+			w = w*58 + uint64(digit)
+			scale *= 58
+		}
+
+		n.Mul(n, word.SetUint64(scale))
+		n.Add(n, word.SetUint64(w))
+
+		if n.BitLen() > 8*maxBase58Len {
+			return nil, errors.Wrapf(ErrEncoding, "base58 input too long (max %d bytes)", maxBase58Len)
+		}
+	}
+
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+// base58Writer buffers data written to it, and writes it to w as base-58 on Close
+type base58Writer struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (b *base58Writer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > maxBase58Len {
+		return 0, errors.Wrapf(ErrEncoding, "input too long for base58 (max %d bytes)", maxBase58Len)
+	}
+
+	return b.buf.Write(p)
+}
+
+func (b *base58Writer) Close() error {
+	encoded, err := encodeBase58(b.buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.w.Write(encoded); err != nil {
+		return errors.Wrap(err, "failed to write base58")
+	}
+
+	return nil
+}
+
+// base58Reader reads base-58 from r, and decodes it on first Read
+type base58Reader struct {
+	r       io.Reader
+	decoded *bytes.Reader
+}
+
+func (b *base58Reader) Read(p []byte) (int, error) {
+	if b.decoded == nil {
+		// Encoded length is at most 1.37x of decoded length, plus a trailing newline
+		encoded, err := io.ReadAll(io.LimitReader(b.r, 2*maxBase58Len+1))
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to read base58")
+		}
+
+		if len(encoded) > 2*maxBase58Len {
+			return 0, errors.Wrapf(ErrEncoding, "base58 input too long (max %d bytes)", maxBase58Len)
+		}
+
+		decoded, err := decodeBase58(encoded)
+		if err != nil {
+			return 0, err
+		}
+
+		b.decoded = bytes.NewReader(decoded)
+	}
+
+	return b.decoded.Read(p)
+}
@@ -0,0 +1,193 @@
+package gfc
+
+// This file provides compression functionality for gfc.
+// Supported algorithms are zstd, gzip, S2, and Snappy (framed, compatible with S2 readers),
+// all from github.com/klauspost/compress. Level 0 always means the default level of the algorithm.
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// maxZstdDecoderMemory limits memory used by zstd decoder, so that a few bytes of
+// crafted input cannot make it allocate gigabytes. gfc writes zstd frames with much smaller windows.
+const maxZstdDecoderMemory = 256 << 20
+
+// Compression levels of S2
+const (
+	LevelS2Fast = iota + 1
+	LevelS2Better
+	LevelS2Best
+)
+
+func (compression Compression) String() string {
+	switch compression {
+	case CompressionNone:
+		return "none"
+
+	case CompressionZstd:
+		return "zstd"
+
+	case CompressionGzip:
+		return "gzip"
+
+	case CompressionS2:
+		return "s2"
+
+	case CompressionSnappy:
+		return "snappy"
+	}
+
+	return "invalid compression"
+}
+
+// Compress compresses raw with compression at level
+func Compress(compression Compression, level int, raw Buffer) (Buffer, error) {
+	if compression == CompressionNone && level == 0 {
+		return raw, nil
+	}
+
+	compressed := new(bytes.Buffer)
+	compressor, err := NewCompressWriter(compressed, compression, level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := raw.WriteTo(compressor); err != nil {
+		return nil, errors.Wrapf(err, "failed to compress with %s", compression)
+	}
+
+	if err := compressor.Close(); err != nil {
+		return nil, errors.Wrapf(err, "failed to close %s compressor", compression)
+	}
+
+	return compressed, nil
+}
+
+// Decompress decompresses raw compressed with compression
+func Decompress(compression Compression, raw Buffer) (Buffer, error) {
+	if compression == CompressionNone {
+		return raw, nil
+	}
+
+	decompressor, err := NewDecompressReader(raw, compression)
+	if err != nil {
+		return nil, err
+	}
+
+	defer decompressor.Close()
+
+	decompressed := new(bytes.Buffer)
+	if _, err := decompressed.ReadFrom(decompressor); err != nil {
+		return nil, errors.Wrapf(ErrCompression, "failed to decompress with %s: %s", compression, err)
+	}
+
+	return decompressed, nil
+}
+
+// NewCompressWriter returns a writer which compresses data written to it with compression at level,
+// and writes it to w. With CompressionNone, data is written to w as it is. Callers must call Close
+// to flush the compressed data. Close does not close w.
+func NewCompressWriter(w io.Writer, compression Compression, level int) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionNone:
+		if level != 0 {
+			return nil, errors.Wrapf(ErrCompression, "bad level %d for no compression", level)
+		}
+
+		return nopWriteCloser{w}, nil
+
+	case CompressionZstd:
+		opts := []zstd.EOption{}
+		if level != 0 {
+			if level < 1 || level > 22 {
+				return nil, errors.Wrapf(ErrCompression, "bad zstd level %d, expecting 1-22", level)
+			}
+
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+
+		compressor, err := zstd.NewWriter(w, opts...)
+		if err != nil {
+			return nil, errors.Wrap(err, "new zstd compressor failed")
+		}
+
+		return compressor, nil
+
+	case CompressionGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		} else if level < gzip.BestSpeed || level > gzip.BestCompression {
+			return nil, errors.Wrapf(ErrCompression, "bad gzip level %d, expecting 1-9", level)
+		}
+
+		compressor, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, errors.Wrap(err, "new gzip compressor failed")
+		}
+
+		return compressor, nil
+
+	case CompressionS2:
+		var opts []s2.WriterOption
+		switch level {
+		case 0, LevelS2Fast:
+		case LevelS2Better:
+			opts = append(opts, s2.WriterBetterCompression())
+
+		case LevelS2Best:
+			opts = append(opts, s2.WriterBestCompression())
+
+		default:
+			return nil, errors.Wrapf(ErrCompression, "bad s2 level %d, expecting 1-3", level)
+		}
+
+		return s2.NewWriter(w, opts...), nil
+
+	case CompressionSnappy:
+		if level != 0 {
+			return nil, errors.Wrapf(ErrCompression, "snappy has no levels, got %d", level)
+		}
+
+		return s2.NewWriter(w, s2.WriterSnappyCompat()), nil
+	}
+
+	return nil, errors.Wrapf(ErrCompression, "unknown compression %d", compression)
+}
+
+// NewDecompressReader returns a reader which decompresses data compressed with compression from r.
+// With CompressionNone, data is read from r as it is.
+// Callers must call Close to release decompressor resources.
+func NewDecompressReader(r io.Reader, compression Compression) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionNone:
+		return io.NopCloser(r), nil
+
+	case CompressionZstd:
+		decompressor, err := zstd.NewReader(r, zstd.WithDecoderMaxMemory(maxZstdDecoderMemory))
+		if err != nil {
+			return nil, errors.Wrap(err, "new zstd decoder failed")
+		}
+
+		return decompressor.IOReadCloser(), nil
+
+	case CompressionGzip:
+		decompressor, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, errors.Wrapf(ErrCompression, "bad gzip input: %s", err)
+		}
+
+		return decompressor, nil
+
+	// S2 reader also reads framed Snappy
+	case CompressionS2, CompressionSnappy:
+		return io.NopCloser(s2.NewReader(r)), nil
+	}
+
+	return nil, errors.Wrapf(ErrCompression, "unknown compression %d", compression)
+}
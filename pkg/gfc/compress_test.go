@@ -0,0 +1,90 @@
+package gfc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestCompDecomp(t *testing.T) {
+	b := bytes.Repeat([]byte("this is the input to be compressed "), 64)
+
+	tests := []struct {
+		compression Compression
+		level       int
+	}{
+		{CompressionNone, 0},
+		{CompressionZstd, 0},
+		{CompressionZstd, 1},
+		{CompressionZstd, 19},
+		{CompressionGzip, 0},
+		{CompressionGzip, 9},
+		{CompressionS2, 0},
+		{CompressionS2, LevelS2Best},
+		{CompressionSnappy, 0},
+	}
+
+	for _, test := range tests {
+		compressed, err := Compress(test.compression, test.level, bytes.NewBuffer(b))
+		if err != nil {
+			t.Fatalf("%s:%d: error compressing: %s", test.compression, test.level, err.Error())
+		}
+
+		if test.compression != CompressionNone && compressed.Len() >= len(b) {
+			t.Fatalf("%s:%d: output not compressed", test.compression, test.level)
+		}
+
+		decompressed, err := Decompress(test.compression, compressed)
+		if err != nil {
+			t.Fatalf("%s:%d: error decompressing: %s", test.compression, test.level, err.Error())
+		}
+
+		if !bytes.Equal(b, decompressed.(*bytes.Buffer).Bytes()) {
+			t.Fatalf("%s:%d: unexpected output", test.compression, test.level)
+		}
+	}
+}
+
+func TestCompressBadLevel(t *testing.T) {
+	tests := []struct {
+		compression Compression
+		level       int
+	}{
+		{CompressionNone, 1},
+		{CompressionZstd, 23},
+		{CompressionGzip, 10},
+		{CompressionS2, 4},
+		{CompressionSnappy, 1},
+		{Compression(0xff), 0},
+	}
+
+	for _, test := range tests {
+		if _, err := Compress(test.compression, test.level, bytes.NewBufferString("foo")); !errors.Is(err, ErrCompression) {
+			t.Fatalf("%s:%d: expecting ErrCompression, got %v", test.compression, test.level, err)
+		}
+	}
+}
+
+func TestCompressionHeader(t *testing.T) {
+	key := make([]byte, aes256BitKeyFileLen)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("error filling random key bytes: %s", err.Error())
+	}
+
+	for _, compression := range []Compression{CompressionNone, CompressionZstd, CompressionGzip, CompressionS2, CompressionSnappy} {
+		ciphertext, err := EncryptGCM(bytes.NewBufferString("foo"), key, WithCompression(compression))
+		if err != nil {
+			t.Fatalf("%s: error encrypting: %s", compression, err.Error())
+		}
+
+		hdr, err := ParseHeader(ciphertext.Bytes())
+		if err != nil {
+			t.Fatalf("%s: error parsing header: %s", compression, err.Error())
+		}
+
+		if hdr.Compression != compression || (hdr.Flags&FlagCompressed != 0) != (compression != CompressionNone) {
+			t.Fatalf("%s: unexpected header compression %s, flags %d", compression, hdr.Compression, hdr.Flags)
+		}
+	}
+}
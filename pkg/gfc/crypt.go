@@ -0,0 +1,295 @@
+package gfc
+
+// This file provides the io.Reader and io.Writer based gfc API for all algorithms.
+// Buffer functions (e.g. EncryptGCM and DecryptGCM) are thin wrappers around
+// NewEncryptWriter and NewDecryptReader.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// NewEncryptWriter returns a writer which encrypts data written to it with mode,
+// and writes gfc output to w. For symmetric key modes, a passphrase is used if key is nil.
+// For RSA, key is PEM-encoded public key. For X25519, key is a list of text-encoded
+// public keys (one per line), and the output can be decrypted with any of their private keys.
+// For Multi-Recipient, key is ignored, and recipients are given with WithRecipients.
+//
+// RSA256-OEAP can only encrypt short messages, while hybrid modes
+// (e.g. RSA256-OEAP-Hybrid) can encrypt messages of any length.
+//
+// AEAD modes (AES256-GCM and (X)ChaCha20-Poly1305) are encrypted as chunked stream
+// with constant memory, unless WithChunkSize(0) is given. Other modes may buffer
+// the plaintext until Close. Callers must call Close to flush the output.
+// Close does not close w.
+func NewEncryptWriter(w io.Writer, mode AlgoMode, key []byte, opts ...Option) (io.WriteCloser, error) {
+	o := newOptions(opts)
+
+	encrypter, err := newEncryptWriter(w, mode, key, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// Metadata is encrypted as the start of the plaintext (see metadata.go)
+	if o.metadata != nil {
+		if err := writeMetadata(encrypter, o.metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	return encrypter, nil
+}
+
+func newEncryptWriter(w io.Writer, mode AlgoMode, key []byte, o *options) (io.WriteCloser, error) {
+	switch mode {
+	case ModeAesGCM, ModeXChaCha20Poly1305, ModeChaCha20Poly1305:
+		return newEncryptWriterAEAD(w, mode, key, o)
+
+	case ModeAesCTR:
+		return newEncryptWriterCTR(w, key, o)
+
+	case ModeRsaOEAP:
+		return newEncryptWriterRSA(w, key, o)
+
+	case ModeRsaHybrid:
+		return newEncryptWriterRSAHybrid(w, key, o)
+
+	case ModeX25519:
+		return newEncryptWriterX25519(w, key, o)
+
+	case ModeMultiRecipient:
+		return newEncryptWriterHybrid(w, mode, o.recipients, o)
+	}
+
+	return nil, errors.Wrapf(ErrInvalidMode, "mode %d", mode)
+}
+
+// NewDecryptReader returns a reader which decrypts gfc output read from r.
+// The algorithm and mode are read from the header. Chunked output is decrypted
+// with constant memory, while other output is read to memory first.
+// Output without header (written by older gfc) is decrypted with mode set by WithMode.
+func NewDecryptReader(r io.Reader, key []byte, opts ...Option) (io.Reader, error) {
+	o := newOptions(opts)
+
+	decrypter, hdr, err := newDecryptReader(r, key, o)
+	if err != nil {
+		return nil, err
+	}
+
+	if hdr == nil || hdr.Flags&FlagMetadata == 0 {
+		return decrypter, nil
+	}
+
+	// Metadata is stripped from the plaintext, even if the caller does not read it
+	md, err := readMetadata(decrypter)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.metadataOut != nil {
+		*o.metadataOut = *md
+	}
+
+	return decrypter, nil
+}
+
+// newDecryptReader returns decrypting reader and header of gfc output read from r.
+// The header is nil for output without header.
+func newDecryptReader(r io.Reader, key []byte, o *options) (io.Reader, *Header, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(len(headerMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, errors.Wrap(err, "failed to read header")
+	}
+
+	if !hasHeader(magic) {
+		ciphertext, err := io.ReadAll(br)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to read ciphertext")
+		}
+
+		decrypter, err := decryptLegacy(ciphertext, key, o)
+		return decrypter, nil, err
+	}
+
+	hdr, header, err := readHeader(br)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkAAD(hdr, o); err != nil {
+		return nil, nil, err
+	}
+
+	decrypter, err := newDecryptReaderMode(br, hdr, associatedData(header, o), key, o)
+
+	return decrypter, hdr, err
+}
+
+// newDecryptReaderMode returns decrypting reader for ciphertext following header hdr,
+// which is authenticated with aad
+func newDecryptReaderMode(br *bufio.Reader, hdr *Header, aad []byte, key []byte, o *options) (io.Reader, error) {
+	if isHybridMode(hdr.Mode) && len(o.identities) != 0 {
+		return newDecryptReaderHybrid(br, hdr, aad, o.identities)
+	}
+
+	switch hdr.Mode {
+	case ModeAesGCM, ModeXChaCha20Poly1305, ModeChaCha20Poly1305:
+		return newDecryptReaderAEAD(br, hdr, aad, key, o)
+
+	case ModeAesCTR:
+		return newDecryptReaderCTR(br, hdr, aad, key, o)
+
+	case ModeAesCTRLegacy:
+		return newDecryptReaderCTRLegacy(br, hdr, key, o)
+
+	case ModeRsaOEAP:
+		return newDecryptReaderRSA(br, aad, key)
+
+	case ModeRsaHybrid:
+		return newDecryptReaderRSAHybrid(br, hdr, aad, key)
+
+	case ModeX25519:
+		return newDecryptReaderX25519(br, hdr, aad, key)
+
+	case ModeMultiRecipient:
+		identities, err := ParseIdentities(key)
+		if err != nil {
+			return nil, err
+		}
+
+		return newDecryptReaderHybrid(br, hdr, aad, identities)
+	}
+
+	return nil, errors.Wrapf(ErrInvalidMode, "mode %s", hdr.Mode)
+}
+
+// PeekHeader parses the header of gfc output read from r, and returns it with a reader
+// which still yields the whole output, e.g. for NewDecryptReader.
+// The returned header is nil if the output has no header (written by older gfc).
+func PeekHeader(r io.Reader) (*Header, io.Reader, error) {
+	br := bufio.NewReaderSize(r, lenHeaderFixed+math.MaxUint16)
+
+	raw, err := br.Peek(lenHeaderFixed)
+	if !hasHeader(raw) {
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, errors.Wrap(err, "failed to read header")
+		}
+
+		return nil, br, nil
+	}
+
+	lenBody := int(binary.BigEndian.Uint16(raw[len(headerMagic)+1:]))
+	if raw, err = br.Peek(lenHeaderFixed + lenBody); err != nil {
+		return nil, nil, errors.Wrap(ErrUnmarshalHeader, "header too short")
+	}
+
+	hdr, _, err := parseHeader(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return hdr, br, nil
+}
+
+// readHeader reads serialized gfc header from r
+func readHeader(r io.Reader) (*Header, []byte, error) {
+	raw := make([]byte, lenHeaderFixed)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, nil, errors.Wrap(ErrUnmarshalHeader, "header too short")
+	}
+
+	if !hasHeader(raw) {
+		return nil, nil, errors.Wrap(ErrUnmarshalHeader, "missing gfc header magic")
+	}
+
+	lenBody := int(binary.BigEndian.Uint16(raw[len(headerMagic)+1:]))
+	raw = append(raw, make([]byte, lenBody)...)
+
+	if _, err := io.ReadFull(r, raw[lenHeaderFixed:]); err != nil {
+		return nil, nil, errors.Wrap(ErrUnmarshalHeader, "header too short")
+	}
+
+	hdr, _, err := parseHeader(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return hdr, raw, nil
+}
+
+// decryptLegacy decrypts output written by gfc before header was introduced
+func decryptLegacy(ciphertext []byte, key []byte, o *options) (io.Reader, error) {
+	if err := checkAAD(nil, o); err != nil {
+		return nil, err
+	}
+
+	switch o.mode {
+	case ModeAesGCM, ModeXChaCha20Poly1305, ModeChaCha20Poly1305:
+		return decryptLegacyAEAD(ciphertext, key, o)
+
+	// AES256-CTR output without header is always unauthenticated
+	case ModeAesCTR, ModeAesCTRLegacy:
+		return decryptLegacyCTR(ciphertext, key, o)
+
+	// Hybrid output always has header, so RSA output without header is RSA256-OEAP
+	case ModeRsaOEAP, ModeRsaHybrid:
+		return decryptLegacyRSA(ciphertext, key)
+	}
+
+	return nil, errors.Wrapf(ErrInvalidMode, "cannot decrypt output without header with mode %s", o.mode)
+}
+
+// encryptBuffer encrypts plaintext with NewEncryptWriter. AEAD modes are encrypted
+// in one shot, unless chunk size is given in opts.
+func encryptBuffer(mode AlgoMode, plaintext Buffer, key []byte, opts []Option) (Buffer, error) {
+	ciphertext := new(bytes.Buffer)
+
+	w, err := NewEncryptWriter(ciphertext, mode, key, append([]Option{WithChunkSize(0)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.Copy(w, plaintext); err != nil {
+		return nil, errors.Wrapf(err, "%s encryption", mode)
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, errors.Wrapf(err, "%s encryption", mode)
+	}
+
+	return ciphertext, nil
+}
+
+// decryptBuffer decrypts ciphertext with NewDecryptReader.
+// Decryption fails if the ciphertext was not encrypted with mode.
+func decryptBuffer(mode AlgoMode, ciphertext Buffer, key []byte, opts []Option) (Buffer, error) {
+	if hasHeader(ciphertext.Bytes()) {
+		hdr, err := ParseHeader(ciphertext.Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Mode != mode {
+			return nil, errors.Wrapf(ErrHeaderMode, "ciphertext was encrypted with %s, not %s", hdr.Mode, mode)
+		}
+	}
+
+	r, err := NewDecryptReader(ciphertext, key, append([]Option{WithMode(mode)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := new(bytes.Buffer)
+	if _, err := plaintext.ReadFrom(r); err != nil {
+		return nil, errors.Wrapf(err, "%s decryption", mode)
+	}
+
+	return plaintext, nil
+}
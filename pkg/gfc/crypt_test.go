@@ -0,0 +1,84 @@
+package gfc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"os"
+	"testing"
+)
+
+// wrappedBuffer is a Buffer implementation other than *bytes.Buffer
+type wrappedBuffer struct {
+	*bytes.Buffer
+}
+
+func TestReaderWriter(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("this is my plaintext"), 100)
+
+	key := make([]byte, aes256BitKeyFileLen)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("error filling random key bytes: %s", err.Error())
+	}
+
+	modes := []AlgoMode{ModeAesGCM, ModeAesCTR, ModeXChaCha20Poly1305, ModeChaCha20Poly1305}
+	for _, mode := range modes {
+		ciphertext := new(bytes.Buffer)
+
+		w, err := NewEncryptWriter(ciphertext, mode, key)
+		if err != nil {
+			t.Fatalf("%s: error creating encrypt writer: %s", mode, err.Error())
+		}
+
+		// Write in small pieces, like from a pipe
+		if _, err := io.CopyBuffer(w, bytes.NewReader(plaintext), make([]byte, 7)); err != nil {
+			t.Fatalf("%s: error writing plaintext: %s", mode, err.Error())
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatalf("%s: error closing encrypt writer: %s", mode, err.Error())
+		}
+
+		r, err := NewDecryptReader(ciphertext, key)
+		if err != nil {
+			t.Fatalf("%s: error creating decrypt reader: %s", mode, err.Error())
+		}
+
+		decrypted, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: error decrypting: %s", mode, err.Error())
+		}
+
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatalf("%s: output does not match", mode)
+		}
+	}
+}
+
+func TestRSABuffer(t *testing.T) {
+	pubPEM, err := os.ReadFile("../../assets/files/pub.pem")
+	if err != nil {
+		t.Skipf("failed to read public key file: %s", err.Error())
+	}
+
+	priPEM, err := os.ReadFile("../../assets/files/pri.pem")
+	if err != nil {
+		t.Skipf("failed to read private key file: %s", err.Error())
+	}
+
+	plaintext := []byte("this is my plaintext")
+
+	ciphertext, err := EncryptRSA(wrappedBuffer{bytes.NewBuffer(plaintext)}, pubPEM)
+	if err != nil {
+		t.Fatalf("error encrypting: %s", err.Error())
+	}
+
+	decrypted, err := DecryptRSA(wrappedBuffer{bytes.NewBuffer(ciphertext.Bytes())}, priPEM)
+	if err != nil {
+		t.Fatalf("error decrypting: %s", err.Error())
+	}
+
+	if !bytes.Equal(decrypted.Bytes(), plaintext) {
+		t.Fatalf("output does not match: %q", decrypted.Bytes())
+	}
+}
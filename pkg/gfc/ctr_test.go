@@ -0,0 +1,105 @@
+package gfc
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestCTRTampered(t *testing.T) {
+	key := make([]byte, aes256BitKeyFileLen)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("error filling random key bytes: %s", err.Error())
+	}
+
+	ciphertext, err := EncryptCTR(bytes.NewBufferString("this is my plaintext"), key)
+	if err != nil {
+		t.Fatalf("error encrypting with AES256-CTR: %s", err.Error())
+	}
+
+	lenHeader := ciphertext.Len() - len("this is my plaintext") - lenTagCTR
+
+	// Flip bits in ciphertext, tag, and IV (last byte of header)
+	for _, i := range []int{lenHeader, ciphertext.Len() - 1, lenHeader - 1} {
+		tampered := append([]byte{}, ciphertext.Bytes()...)
+		tampered[i] ^= 1
+
+		if _, err := DecryptCTR(bytes.NewBuffer(tampered), key); !errors.Is(err, ErrAuthCTR) {
+			t.Fatalf("byte %d: expecting ErrAuthCTR, got %v", i, err)
+		}
+	}
+
+	// Authenticated output is still verified with DecryptLegacyCTR
+	tampered := append([]byte{}, ciphertext.Bytes()...)
+	tampered[lenHeader] ^= 1
+
+	if _, err := DecryptLegacyCTR(bytes.NewBuffer(tampered), key); !errors.Is(err, ErrAuthCTR) {
+		t.Fatalf("DecryptLegacyCTR: expecting ErrAuthCTR, got %v", err)
+	}
+
+	truncated := ciphertext.Bytes()[:lenHeader+lenTagCTR-1]
+	if _, err := DecryptCTR(bytes.NewBuffer(truncated), key); !errors.Is(err, ErrAuthCTR) {
+		t.Fatalf("truncated: expecting ErrAuthCTR, got %v", err)
+	}
+}
+
+func TestDecryptLegacyCTR(t *testing.T) {
+	plaintext := []byte("this is my legacy plaintext")
+	key := make([]byte, aes256BitKeyFileLen)
+	iv := make([]byte, blockSizeAES256CTR)
+	salt := make([]byte, lenPBKDF2Salt)
+
+	for _, b := range [][]byte{key, iv, salt} {
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("error filling random bytes: %s", err.Error())
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("failed to create AES cipher: %s", err.Error())
+	}
+
+	// <Ciphertext> <Cipher Nonce> <PBKDF2 Salt>
+	legacy := make([]byte, len(plaintext))
+	cipher.NewCTR(block, iv).XORKeyStream(legacy, plaintext)
+	legacy = append(legacy, iv...)
+	legacy = append(legacy, salt...)
+
+	// Unauthenticated CTR output with header
+	hdr, header, subkey, err := newHeaderSymm(ModeAesCTRLegacy, blockSizeAES256CTR, 0, key, newOptions(nil))
+	if err != nil {
+		t.Fatalf("failed to create header: %s", err.Error())
+	}
+
+	subkeyBlock, err := aes.NewCipher(subkey)
+	if err != nil {
+		t.Fatalf("failed to create AES cipher: %s", err.Error())
+	}
+
+	withHeader := make([]byte, len(plaintext))
+	cipher.NewCTR(subkeyBlock, hdr.Nonce).XORKeyStream(withHeader, plaintext)
+	withHeader = append(header, withHeader...)
+
+	for _, ciphertext := range [][]byte{legacy, withHeader} {
+		if _, err := DecryptCTR(bytes.NewBuffer(ciphertext), key); err == nil {
+			t.Fatal("expecting error decrypting unauthenticated output without legacy option")
+		}
+
+		if _, err := NewDecryptReader(bytes.NewReader(ciphertext), key, WithMode(ModeAesCTR)); !errors.Is(err, ErrLegacyCTR) {
+			t.Fatalf("expecting ErrLegacyCTR, got %v", err)
+		}
+
+		decrypted, err := DecryptLegacyCTR(bytes.NewBuffer(ciphertext), key)
+		if err != nil {
+			t.Fatalf("error decrypting legacy output: %s", err.Error())
+		}
+
+		if !bytes.Equal(decrypted.Bytes(), plaintext) {
+			t.Fatal("output does not match")
+		}
+	}
+}
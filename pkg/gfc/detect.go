@@ -0,0 +1,127 @@
+package gfc
+
+// This file provides detection of encoding for decryption,
+// so that users do not have to remember the flags used during encryption.
+//
+// Encoding is detected by looking for the header magic in the input, as it is,
+// or decoded with each encoding, or by looking for the armor BEGIN line. Input without
+// header (output of older gfc) is detected as encoded if every byte of it belongs to
+// the alphabet of the encoding. Longer text input is assumed to be armor surrounded by
+// other text, since the armor BEGIN line may be anywhere in it (see SniffEncoding).
+//
+// Compression is not detected: it is recorded in the header (see Header.Compression), and
+// output without header is only compressed if the user says so, since older gfc only compressed
+// with -c, and a plaintext which is itself compressed (e.g. a .gz file) must decrypt as it is.
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// lenDetectPrefix is the length of input prefix used for detection by SniffEncoding,
+// except for base58, which is detected from the whole input
+const lenDetectPrefix = 512
+
+// DetectEncoding returns the encoding of gfc output starting with prefix.
+// It returns EncodingNone if the encoding cannot be detected.
+func DetectEncoding(prefix []byte) Encoding {
+	if hasHeader(prefix) {
+		return EncodingNone
+	}
+
+	// Armor may be surrounded by other text
+	if bytes.Contains(prefix, []byte(armorBegin)) {
+		return EncodingArmor
+	}
+
+	// Encoded header magic. Base64 and base32 decoding accepts every variant of them.
+	// Base58 can only be detected if prefix is the whole input (see SniffEncoding).
+	for _, encoding := range []Encoding{EncodingHex, EncodingBase64, EncodingBase32, EncodingAscii85, EncodingZ85, EncodingBase58} {
+		decoder, err := NewDecodeReader(bytes.NewReader(prefix), encoding)
+		if err != nil {
+			continue
+		}
+
+		magic := make([]byte, len(headerMagic))
+		if _, err := io.ReadFull(decoder, magic); err == nil && hasHeader(magic) {
+			return encoding
+		}
+	}
+
+	// Output without header, trailing newline may be added by e.g. shell
+	text := bytes.TrimRight(prefix, "\r\n")
+	switch {
+	case len(text) == 0:
+		return EncodingNone
+
+	case inAlphabet(text, "0123456789abcdefABCDEF"):
+		return EncodingHex
+
+	case inAlphabet(text, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/=\r\n"):
+		return EncodingBase64
+	}
+
+	return EncodingNone
+}
+
+// SniffEncoding detects the encoding of gfc output from r with DetectEncoding.
+// Since only a prefix of r is examined, text input longer than the prefix is
+// detected as armor, whose BEGIN line may come after any amount of surrounding text.
+// Callers must read from the returned reader, which still has the detected prefix.
+func SniffEncoding(r io.Reader) (io.Reader, Encoding, error) {
+	br := bufio.NewReaderSize(r, 2*maxBase58Len)
+	prefix, err := peekPrefix(br, lenDetectPrefix)
+	if err != nil {
+		return nil, EncodingNone, err
+	}
+
+	// Base58 can only be detected from the whole input, which is short
+	if inAlphabet(bytes.TrimRight(prefix, "\r\n"), alphabetBase58) {
+		if prefix, err = peekPrefix(br, 2*maxBase58Len); err != nil {
+			return nil, EncodingNone, err
+		}
+	}
+
+	encoding := DetectEncoding(prefix)
+	if encoding == EncodingNone && len(prefix) >= lenDetectPrefix && isText(prefix) {
+		encoding = EncodingArmor
+	}
+
+	return br, encoding, nil
+}
+
+// peekPrefix peeks up to n bytes from br, short input is not an error
+func peekPrefix(br *bufio.Reader, n int) ([]byte, error) {
+	prefix, err := br.Peek(n)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, errors.Wrap(err, "failed to read input prefix")
+	}
+
+	return prefix, nil
+}
+
+// isText reports whether b has no control characters other than whitespace.
+// Random bytes, e.g. raw ciphertext, are almost never text.
+func isText(b []byte) bool {
+	for _, c := range b {
+		if (c < 0x20 && !strings.ContainsRune("\t\n\f\r", rune(c))) || c == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
+
+func inAlphabet(b []byte, alphabet string) bool {
+	for _, c := range b {
+		if strings.IndexByte(alphabet, c) < 0 {
+			return false
+		}
+	}
+
+	return true
+}
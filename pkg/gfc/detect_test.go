@@ -0,0 +1,126 @@
+package gfc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	key := make([]byte, aes256BitKeyFileLen)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("error filling random key bytes: %s", err.Error())
+	}
+
+	for _, encoding := range append([]Encoding{EncodingNone}, allEncodings...) {
+		ciphertext, err := EncryptGCM(bytes.NewBufferString("foo"), key)
+		if err != nil {
+			t.Fatalf("error encrypting: %s", err.Error())
+		}
+
+		encoded, err := Encode(encoding, ciphertext)
+		if err != nil {
+			t.Fatalf("error encoding: %s", err.Error())
+		}
+
+		// Decoding accepts every variant of base64 and base32
+		expected := encoding
+		switch encoding {
+		case EncodingBase64URL, EncodingBase64Raw:
+			expected = EncodingBase64
+
+		case EncodingBase32Raw:
+			expected = EncodingBase32
+		}
+
+		b := encoded.Bytes()
+		if detected := DetectEncoding(b); detected != expected {
+			t.Fatalf("expecting encoding %d, got %d", expected, detected)
+		}
+
+		r, detected, err := SniffEncoding(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("error sniffing encoding: %s", err.Error())
+		}
+
+		if detected != expected {
+			t.Fatalf("sniff: expecting encoding %d, got %d", expected, detected)
+		}
+
+		if sniffed, _ := io.ReadAll(r); !bytes.Equal(sniffed, b) {
+			t.Fatalf("sniff: unexpected output for encoding %d", encoding)
+		}
+	}
+
+	// Output without header
+	legacy := map[string]Encoding{
+		"\x00\x01\x02\x03":     EncodingNone,
+		"00a1ff\n":             EncodingHex,
+		"AAECAw==\r\n":         EncodingBase64,
+		"":                     EncodingNone,
+		"plaintext with space": EncodingNone,
+	}
+
+	for input, expected := range legacy {
+		if detected := DetectEncoding([]byte(input)); detected != expected {
+			t.Fatalf("%q: expecting encoding %d, got %d", input, expected, detected)
+		}
+	}
+}
+
+func TestSniffEncodingArmor(t *testing.T) {
+	key := make([]byte, aes256BitKeyFileLen)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("error filling random key bytes: %s", err.Error())
+	}
+
+	plaintext := []byte("this is my plaintext")
+	ciphertext, err := EncryptGCM(bytes.NewBuffer(plaintext), key)
+	if err != nil {
+		t.Fatalf("error encrypting: %s", err.Error())
+	}
+
+	armored, err := Encode(EncodingArmor, ciphertext)
+	if err != nil {
+		t.Fatalf("error encoding: %s", err.Error())
+	}
+
+	// Armor after more surrounding text than the detection prefix, e.g. a pasted email
+	text := bytes.Repeat([]byte("> quoted reply line\r\n"), 4*lenDetectPrefix/20)
+	input := append(append(text, armored.Bytes()...), "\nSent from my phone\n"...)
+
+	r, detected, err := SniffEncoding(bytes.NewReader(input))
+	if err != nil {
+		t.Fatalf("error sniffing encoding: %s", err.Error())
+	}
+
+	if detected != EncodingArmor {
+		t.Fatalf("expecting armor, got encoding %d", detected)
+	}
+
+	sniffed, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("error reading sniffed input: %s", err.Error())
+	}
+
+	decoded, err := Decode(detected, bytes.NewBuffer(sniffed))
+	if err != nil {
+		t.Fatalf("error decoding: %s", err.Error())
+	}
+
+	decrypted, err := DecryptGCM(decoded, key)
+	if err != nil || !bytes.Equal(decrypted.Bytes(), plaintext) {
+		t.Fatalf("unexpected output (%v)", err)
+	}
+
+	// Raw ciphertext is not text
+	long := make([]byte, 2*lenDetectPrefix)
+	if _, err := rand.Read(long); err != nil {
+		t.Fatalf("error filling random bytes: %s", err.Error())
+	}
+
+	if _, detected, _ := SniffEncoding(bytes.NewReader(long)); detected != EncodingNone {
+		t.Fatalf("random bytes: expecting no encoding, got %d", detected)
+	}
+}
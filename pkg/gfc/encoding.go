@@ -1,77 +1,177 @@
 package gfc
 
 // This file provides encoding functionality for gfc
-// Current supported encodings are none, base-16 (hexadecimal), and base-64.
+// Current supported encodings are none, base-16 (hexadecimal), base-64 (standard, URL-safe, and unpadded),
+// base-32 (padded and unpadded), base-58 (see base58.go), ascii85, Z85 (see z85.go), and ASCII armor (see armor.go).
+//
+// Decoding base-64 and base-32 is lenient: padding is optional, and base-64 accepts
+// both standard and URL-safe alphabets, so any variant can be decoded with any of its encodings.
 
 import (
 	"bytes"
+	"encoding/ascii85"
+	"encoding/base32"
 	"encoding/base64"
 	"encoding/hex"
-	"fmt"
 	"io"
 
 	"github.com/pkg/errors"
 )
 
 func Decode(encoding Encoding, raw Buffer) (Buffer, error) {
-	var decoder io.Reader
-
-	switch encoding {
-	case EncodingNone:
+	if encoding == EncodingNone {
 		return raw, nil
+	}
 
-	case EncodingBase64:
-		decoder = base64.NewDecoder(base64.StdEncoding, raw)
-
-	case EncodingHex:
-		decoder = hex.NewDecoder(raw)
-
-	default:
-		return nil, fmt.Errorf("unknown encoding %d", encoding)
+	decoder, err := NewDecodeReader(raw, encoding)
+	if err != nil {
+		return nil, err
 	}
 
 	decoded := new(bytes.Buffer)
-	_, err := decoded.ReadFrom(decoder)
-	if err != nil {
-		return nil, errors.Wrap(err, "io error - cannot read from decoder")
+	if _, err := decoded.ReadFrom(decoder); err != nil {
+		return nil, errors.Wrapf(ErrEncoding, "cannot decode input: %s", err)
 	}
 
 	return decoded, nil
 }
 
 func Encode(encoding Encoding, raw Buffer) (Buffer, error) {
-	// Need empty interface because base64.NewEncoder returns io.WriteCloser,
-	// while hex.NewEncoder returns io.Writer
-	var encoder interface{}
+	if encoding == EncodingNone {
+		return raw, nil
+	}
+
 	encoded := new(bytes.Buffer)
+	encoder, err := NewEncodeWriter(encoded, encoding)
+	if err != nil {
+		return nil, err
+	}
 
+	if _, err := raw.WriteTo(encoder); err != nil {
+		return nil, errors.Wrap(err, "io error: can't write to encoder")
+	}
+
+	// Base64 encodings operate in 4-byte blocks; Close flushes any partially written blocks,
+	// and writes the armor checksum.
+	if err := encoder.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close encoder")
+	}
+
+	return encoded, nil
+}
+
+// NewDecodeReader returns a reader which decodes encoding from r
+func NewDecodeReader(r io.Reader, encoding Encoding) (io.Reader, error) {
 	switch encoding {
 	case EncodingNone:
-		return raw, nil
+		return r, nil
+
+	case EncodingBase64, EncodingBase64URL, EncodingBase64Raw:
+		return base64.NewDecoder(base64.RawStdEncoding, &normalizeReader{r: r, base64: true}), nil
+
+	case EncodingBase32, EncodingBase32Raw:
+		return base32.NewDecoder(base32.StdEncoding.WithPadding(base32.NoPadding), &normalizeReader{r: r}), nil
+
+	case EncodingHex:
+		return hex.NewDecoder(r), nil
+
+	case EncodingBase58:
+		return &base58Reader{r: r}, nil
+
+	case EncodingAscii85:
+		return ascii85.NewDecoder(r), nil
+
+	case EncodingZ85:
+		return ascii85.NewDecoder(&z85Reader{r: r}), nil
+
+	case EncodingArmor:
+		dearmored, _, err := NewArmorReader(r)
+		return dearmored, err
+	}
+
+	return nil, errors.Wrapf(ErrEncoding, "unknown encoding %d", encoding)
+}
+
+// NewEncodeWriter returns a writer which encodes data written to it with encoding, and writes it to w.
+// Callers must call Close to flush any partially written blocks. Close does not close w.
+func NewEncodeWriter(w io.Writer, encoding Encoding) (io.WriteCloser, error) {
+	switch encoding {
+	case EncodingNone:
+		return nopWriteCloser{w}, nil
 
 	case EncodingBase64:
-		encoder = base64.NewEncoder(base64.StdEncoding, encoded)
+		return base64.NewEncoder(base64.StdEncoding, w), nil
+
+	case EncodingBase64URL:
+		return base64.NewEncoder(base64.RawURLEncoding, w), nil
 
-		// Base64 encodings operate in 4-byte blocks; when finished writing,
-		// the caller must Close the returned encoder to flush any partially written blocks.
-		defer func() {
-			err := encoder.(io.WriteCloser).Close()
-			if err != nil {
-				panic("failed to close base64 encoder: " + err.Error())
-			}
-		}()
+	case EncodingBase64Raw:
+		return base64.NewEncoder(base64.RawStdEncoding, w), nil
+
+	case EncodingBase32:
+		return base32.NewEncoder(base32.StdEncoding, w), nil
+
+	case EncodingBase32Raw:
+		return base32.NewEncoder(base32.StdEncoding.WithPadding(base32.NoPadding), w), nil
 
 	case EncodingHex:
-		encoder = hex.NewEncoder(encoded)
+		return nopWriteCloser{hex.NewEncoder(w)}, nil
+
+	case EncodingBase58:
+		return &base58Writer{w: w}, nil
+
+	case EncodingAscii85:
+		return ascii85.NewEncoder(w), nil
 
-	default:
-		return nil, errors.New("unknown encoding")
+	case EncodingZ85:
+		return ascii85.NewEncoder(&z85Writer{w: w}), nil
+
+	case EncodingArmor:
+		return NewArmorWriter(w), nil
 	}
 
-	_, err := raw.WriteTo(encoder.(io.Writer))
-	if err != nil {
-		return nil, errors.Wrap(err, "io error: can't write to encoder")
+	return nil, errors.Wrapf(ErrEncoding, "unknown encoding %d", encoding)
+}
+
+// normalizeReader drops padding from base-64 or base-32 read from r.
+// With base64, URL-safe alphabet is also translated to standard alphabet.
+type normalizeReader struct {
+	r      io.Reader
+	base64 bool
+}
+
+func (n *normalizeReader) Read(p []byte) (int, error) {
+	read, err := n.r.Read(p)
+
+	kept := 0
+	for _, c := range p[:read] {
+		switch {
+		case c == '=':
+			continue
+
+		case n.base64 && c == '-':
+			c = '+'
+
+		case n.base64 && c == '_':
+			c = '/'
+		}
+
+		p[kept] = c
+		kept++
 	}
 
-	return encoded, nil
+	// Never return 0 bytes without error, which io.Reader discourages
+	if kept == 0 && read != 0 && err == nil {
+		return n.Read(p)
+	}
+
+	return kept, err
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
 }
@@ -0,0 +1,82 @@
+package gfc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var allEncodings = []Encoding{
+	EncodingBase64,
+	EncodingHex,
+	EncodingArmor,
+	EncodingBase64URL,
+	EncodingBase64Raw,
+	EncodingBase32,
+	EncodingBase32Raw,
+	EncodingBase58,
+	EncodingAscii85,
+	EncodingZ85,
+}
+
+func TestEncoding(t *testing.T) {
+	vectors := []struct {
+		encoding Encoding
+		decoded  []byte
+		encoded  string
+	}{
+		{EncodingBase64URL, []byte{0xfb, 0xff, 0xbf}, "-_-_"},
+		{EncodingBase64Raw, []byte("fo"), "Zm8"},
+		{EncodingBase32, []byte("fo"), "MZXQ===="},
+		{EncodingBase32Raw, []byte("fo"), "MZXQ"},
+		{EncodingBase58, []byte("Hello World!"), "2NEpo7TZRRrLZSi2U"},
+		{EncodingBase58, []byte{0, 0, 0x28, 0x7f, 0xb4, 0xcd}, "11233QC4"},
+		{EncodingBase58, []byte{}, ""},
+		{EncodingAscii85, []byte("sure"), "F*2M7"},
+		{EncodingZ85, []byte{0x86, 0x4f, 0xd2, 0x6f, 0xb5, 0x59, 0xf7, 0x5b}, "HelloWorld"},
+		{EncodingZ85, []byte{0, 0, 0, 0}, "00000"},
+	}
+
+	for _, vector := range vectors {
+		encoded, err := Encode(vector.encoding, bytes.NewBuffer(vector.decoded))
+		if err != nil {
+			t.Fatalf("encoding %d: error encoding: %s", vector.encoding, err.Error())
+		}
+
+		if string(encoded.Bytes()) != vector.encoded {
+			t.Fatalf("encoding %d: expecting %q, got %q", vector.encoding, vector.encoded, string(encoded.Bytes()))
+		}
+
+		decoded, err := Decode(vector.encoding, bytes.NewBufferString(vector.encoded))
+		if err != nil {
+			t.Fatalf("encoding %d: error decoding: %s", vector.encoding, err.Error())
+		}
+
+		if !bytes.Equal(decoded.Bytes(), vector.decoded) {
+			t.Fatalf("encoding %d: expecting %x, got %x", vector.encoding, vector.decoded, decoded.Bytes())
+		}
+	}
+
+	// Lenient decoding of base64 and base32 variants
+	for _, encoded := range []string{"-_-_", "+/+/", "+_-/"} {
+		decoded, err := Decode(EncodingBase64, bytes.NewBufferString(encoded))
+		if err != nil || !bytes.Equal(decoded.Bytes(), []byte{0xfb, 0xff, 0xbf}) {
+			t.Fatalf("%s: unexpected base64 output %v, error %v", encoded, decoded, err)
+		}
+	}
+
+	if decoded, err := Decode(EncodingBase32Raw, bytes.NewBufferString("MZXQ====")); err != nil || string(decoded.Bytes()) != "fo" {
+		t.Fatalf("unexpected base32 output %v, error %v", decoded, err)
+	}
+
+	for _, encoding := range []Encoding{EncodingBase58, EncodingZ85} {
+		if _, err := Decode(encoding, bytes.NewBufferString("0OIl\"\\")); err == nil {
+			t.Fatalf("encoding %d: expecting error decoding bad characters", encoding)
+		}
+	}
+
+	long := bytes.NewBufferString(strings.Repeat("x", maxBase58Len+1))
+	if _, err := Encode(EncodingBase58, long); err == nil {
+		t.Fatal("expecting error encoding long input with base58")
+	}
+}
@@ -1,10 +1,13 @@
 package gfc
 
-type gfcError int
+// Error is the type of all errors returned by gfc, wrapped with context.
+// Specific errors (e.g. ErrOpenGCM) also match their error class (e.g. ErrAuth) with errors.Is,
+// so callers can handle e.g. any authentication failure without knowing the algorithm.
+type Error int
 
 const (
 	// Default
-	NoError gfcError = iota
+	NoError Error = iota
 	// Error PBDKF2 key and salt derivation
 	ErrPBKDF2KeySalt
 	// Error unmarshaling gfc symmetric key output
@@ -33,9 +36,133 @@ const (
 	ErrNewCipherXChaCha20Poly1305
 	// Error XChaCha20Poly1305 Open
 	ErrOpenXChaCha20Poly1305
+	// Error marshaling gfc header
+	ErrMarshalHeader
+	// Error unmarshaling gfc header
+	ErrUnmarshalHeader
+	// Error unsupported gfc header version
+	ErrHeaderVersion
+	// Error ciphertext was encrypted with a different mode
+	ErrHeaderMode
+	// Error key source (keyfile or passphrase) differs from the one used for encryption
+	ErrKeySource
+	// Error invalid stream chunk size
+	ErrStreamChunkSize
+	// Error stream chunk authentication
+	ErrOpenStream
+	// Error stream truncated
+	ErrStreamTruncated
+	// Error stream has too many chunks
+	ErrStreamTooLong
+	// Error write to closed stream
+	ErrStreamClosed
+	// Error invalid algorithm mode
+	ErrInvalidMode
+	// Error recipient stanza was not wrapped for this identity
+	ErrStanzaNoMatch
+	// Error no recipient stanza matched any identity
+	ErrNoRecipient
+	// Error unwrapping file key from a matching stanza
+	ErrUnwrapFileKey
+	// Error parsing text-encoded X25519 key
+	ErrParseX25519
+	// Error X25519 computation, e.g. low order public key
+	ErrX25519
+	// Error parsing Ed25519 key
+	ErrParseEd25519
+	// Error parsing gfc signature
+	ErrParseSignature
+	// Error signature does not match message or public keys
+	ErrBadSignature
+	// Error AES-CTR HMAC authentication
+	ErrAuthCTR
+	// Error unauthenticated AES-CTR output without WithLegacyCTR
+	ErrLegacyCTR
+	// Error generating key
+	ErrKeygen
+	// Error archive entry path escapes the extraction directory
+	ErrArchiveUnsafePath
+	// Error unsupported archive entry type
+	ErrArchiveEntry
+	// Error prompting for passphrase, e.g. without terminal
+	ErrPassphrase
+	// Error passphrase confirmation does not match
+	ErrPassphraseMismatch
+	// Error reading from random source
+	ErrRandom
+	// Error unknown or bad encoding
+	ErrEncoding
+	// Error associated data missing or not expected
+	ErrAAD
+	// Error bad encrypted metadata
+	ErrMetadata
+	// Error unknown compression, bad level, or bad compressed data
+	ErrCompression
+	// Error bad KDF parameters, e.g. scrypt N not a power of 2, or too expensive
+	ErrKDFParams
+
+	// Error classes, matched by specific errors above
+
+	// Error class bad or wrong kind of key, e.g. bad keyfile length or unparsable key
+	ErrBadKey
+	// Error class authentication failure, i.e. wrong key or passphrase, or tampered ciphertext
+	ErrAuth
+	// Error class truncated input
+	ErrTruncated
+	// Error class malformed input
+	ErrMalformed
+	// Error class unsupported version, algorithm, or mode
+	ErrUnsupported
 )
 
-func (err gfcError) Error() string {
+// errorClasses maps specific errors to their error class
+var errorClasses = map[Error]Error{
+	ErrInvalidaes256BitKeyFileLen: ErrBadKey,
+	ErrParsePubRSA:                ErrBadKey,
+	ErrParsePriRSA:                ErrBadKey,
+	ErrKeySource:                  ErrBadKey,
+	ErrParseX25519:                ErrBadKey,
+	ErrX25519:                     ErrBadKey,
+	ErrParseEd25519:               ErrBadKey,
+
+	ErrOpenGCM:               ErrAuth,
+	ErrDecryptRSA:            ErrAuth,
+	ErrOpenXChaCha20Poly1305: ErrAuth,
+	ErrOpenStream:            ErrAuth,
+	ErrNoRecipient:           ErrAuth,
+	ErrUnwrapFileKey:         ErrAuth,
+	ErrBadSignature:          ErrAuth,
+	ErrAuthCTR:               ErrAuth,
+	ErrAAD:                   ErrAuth,
+
+	ErrStreamTruncated: ErrTruncated,
+
+	ErrUnmarshalSymmAEAD: ErrMalformed,
+	ErrUnmarshalHeader:   ErrMalformed,
+	ErrStreamChunkSize:   ErrMalformed,
+	ErrStreamTooLong:     ErrMalformed,
+	ErrParseSignature:    ErrMalformed,
+	ErrArchiveUnsafePath: ErrMalformed,
+	ErrArchiveEntry:      ErrMalformed,
+	ErrEncoding:          ErrMalformed,
+	ErrMetadata:          ErrMalformed,
+	ErrCompression:       ErrMalformed,
+	ErrKDFParams:         ErrMalformed,
+
+	ErrHeaderVersion: ErrUnsupported,
+	ErrInvalidMode:   ErrUnsupported,
+	ErrLegacyCTR:     ErrUnsupported,
+
+	ErrPassphraseMismatch: ErrPassphrase,
+}
+
+// Is reports whether target is the error class of err
+func (err Error) Is(target error) bool {
+	class, ok := errorClasses[err]
+	return ok && class == target
+}
+
+func (err Error) Error() string {
 	switch err {
 	case ErrPBKDF2KeySalt:
 		return "PBDKF2 error: key and salt"
@@ -79,6 +206,116 @@ func (err gfcError) Error() string {
 	case ErrOpenXChaCha20Poly1305:
 		return "XChaCha20-Poly1305/ChaCha20-Poly1305 error: decrypt"
 
+	case ErrMarshalHeader:
+		return "header error: failed to marshal gfc header"
+
+	case ErrUnmarshalHeader:
+		return "header error: failed to unmarshal gfc header"
+
+	case ErrHeaderVersion:
+		return "header error: unsupported gfc header version"
+
+	case ErrHeaderMode:
+		return "header error: algorithm mode mismatch"
+
+	case ErrKeySource:
+		return "key error: wrong key source"
+
+	case ErrStreamChunkSize:
+		return "stream error: invalid chunk size"
+
+	case ErrOpenStream:
+		return "stream error: chunk authentication failed"
+
+	case ErrStreamTruncated:
+		return "stream error: truncated ciphertext"
+
+	case ErrStreamTooLong:
+		return "stream error: too many chunks"
+
+	case ErrStreamClosed:
+		return "stream error: write to closed stream"
+
+	case ErrInvalidMode:
+		return "error: invalid algorithm mode"
+
+	case ErrStanzaNoMatch:
+		return "recipient error: stanza does not match identity"
+
+	case ErrNoRecipient:
+		return "recipient error: no matching recipient stanza"
+
+	case ErrUnwrapFileKey:
+		return "recipient error: failed to unwrap file key"
+
+	case ErrParseX25519:
+		return "X25519 error: bad X25519 key"
+
+	case ErrX25519:
+		return "X25519 error: X25519 failed"
+
+	case ErrParseEd25519:
+		return "Ed25519 error: bad Ed25519 key"
+
+	case ErrParseSignature:
+		return "signature error: bad signature format"
+
+	case ErrBadSignature:
+		return "signature error: signature verification failed"
+
+	case ErrAuthCTR:
+		return "AES-CTR error: HMAC authentication failed"
+
+	case ErrLegacyCTR:
+		return "AES-CTR error: unauthenticated legacy AES-CTR output is not allowed"
+
+	case ErrKeygen:
+		return "keygen error: failed to generate key"
+
+	case ErrArchiveUnsafePath:
+		return "archive error: unsafe path in archive"
+
+	case ErrArchiveEntry:
+		return "archive error: unsupported archive entry"
+
+	case ErrPassphrase:
+		return "passphrase error: failed to read passphrase"
+
+	case ErrPassphraseMismatch:
+		return "passphrase error: passphrases do not match"
+
+	case ErrRandom:
+		return "random error: failed to read random bytes"
+
+	case ErrEncoding:
+		return "encoding error: unknown or bad encoding"
+
+	case ErrAAD:
+		return "authentication error: associated data mismatch"
+
+	case ErrMetadata:
+		return "metadata error: bad metadata"
+
+	case ErrCompression:
+		return "compression error: unknown compression, bad level, or bad compressed data"
+
+	case ErrKDFParams:
+		return "KDF error: bad KDF parameters"
+
+	case ErrBadKey:
+		return "key error: bad key"
+
+	case ErrAuth:
+		return "authentication error: wrong key or passphrase, or tampered ciphertext"
+
+	case ErrTruncated:
+		return "input error: truncated input"
+
+	case ErrMalformed:
+		return "input error: malformed input"
+
+	case ErrUnsupported:
+		return "error: unsupported version, algorithm, or mode"
 	}
 
 	return "bad error - should not happen"
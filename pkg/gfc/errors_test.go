@@ -0,0 +1,103 @@
+package gfc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestErrorClasses(t *testing.T) {
+	key := make([]byte, aes256BitKeyFileLen)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("error filling random key bytes: %s", err.Error())
+	}
+
+	plaintext := []byte("this is my plaintext")
+
+	ciphertext, err := EncryptGCM(bytes.NewBuffer(plaintext), key)
+	if err != nil {
+		t.Fatalf("error encrypting with AES256-GCM: %s", err.Error())
+	}
+
+	tampered := append([]byte{}, ciphertext.Bytes()...)
+	tampered[len(tampered)-1] ^= 1
+
+	_, err = DecryptGCM(bytes.NewBuffer(tampered), key)
+	if !errors.Is(err, ErrOpenGCM) || !errors.Is(err, ErrAuth) {
+		t.Fatalf("tampered: expecting ErrOpenGCM and ErrAuth, got %v", err)
+	}
+
+	var gfcErr Error
+	if !errors.As(err, &gfcErr) || gfcErr != ErrOpenGCM {
+		t.Fatalf("tampered: expecting errors.As to find ErrOpenGCM, got %v", gfcErr)
+	}
+
+	if errors.Is(err, ErrTruncated) || errors.Is(err, ErrBadKey) {
+		t.Fatalf("tampered: unexpected error class for %v", err)
+	}
+
+	// Wrong passphrase
+	ciphertext, err = EncryptXChaCha20Poly1305(bytes.NewBuffer(plaintext), nil, WithPassphrase([]byte("my passphrase")), WithPBKDF2(1000, KDFHashSHA256))
+	if err != nil {
+		t.Fatalf("error encrypting with passphrase: %s", err.Error())
+	}
+
+	if _, err := DecryptXChaCha20Poly1305(ciphertext, nil, WithPassphrase([]byte("not my passphrase"))); !errors.Is(err, ErrAuth) {
+		t.Fatalf("wrong passphrase: expecting ErrAuth, got %v", err)
+	}
+
+	// Bad key
+	if _, err := EncryptGCM(bytes.NewBuffer(plaintext), key[1:]); !errors.Is(err, ErrBadKey) {
+		t.Fatalf("short key: expecting ErrBadKey, got %v", err)
+	}
+
+	if _, err := EncryptX25519(bytes.NewBuffer(plaintext), []byte("not a key")); !errors.Is(err, ErrBadKey) {
+		t.Fatalf("bad X25519 key: expecting ErrBadKey, got %v", err)
+	}
+
+	// Unknown encoding
+	if _, err := Encode(Encoding(0xff), bytes.NewBuffer(plaintext)); !errors.Is(err, ErrEncoding) {
+		t.Fatalf("unknown encoding: expecting ErrEncoding, got %v", err)
+	}
+
+	if _, err := Decode(EncodingBase64, bytes.NewBufferString("not base64!")); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("bad base64: expecting ErrMalformed, got %v", err)
+	}
+
+	if !errors.Is(ErrHeaderVersion, ErrUnsupported) || !errors.Is(ErrPassphraseMismatch, ErrPassphrase) {
+		t.Fatal("unexpected error class")
+	}
+}
+
+func TestErrorTruncated(t *testing.T) {
+	key := make([]byte, aes256BitKeyFileLen)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("error filling random key bytes: %s", err.Error())
+	}
+
+	// Input without header is decoded as legacy output, which used to panic on short input
+	for _, n := range []int{0, 1, lenPBKDF2Salt, lenPBKDF2Salt + lenNonceAESGCM256 - 1} {
+		short := make([]byte, n)
+
+		if _, err := DecryptGCM(bytes.NewBuffer(short), key); !errors.Is(err, ErrTruncated) {
+			t.Fatalf("%d bytes GCM: expecting ErrTruncated, got %v", n, err)
+		}
+
+		if _, err := DecryptLegacyCTR(bytes.NewBuffer(short), key); !errors.Is(err, ErrTruncated) {
+			t.Fatalf("%d bytes CTR: expecting ErrTruncated, got %v", n, err)
+		}
+	}
+
+	ciphertext, err := EncryptGCM(bytes.NewBuffer(make([]byte, 1000)), key, WithChunkSize(100))
+	if err != nil {
+		t.Fatalf("error encrypting chunked stream: %s", err.Error())
+	}
+
+	// Leave the last chunk shorter than its tag. Truncation at chunk boundary is detected
+	// as authentication failure instead, because the last chunk is authenticated as such.
+	truncated := ciphertext.Bytes()[:ciphertext.Len()-100-16+5]
+	if _, err := DecryptGCM(bytes.NewBuffer(truncated), key); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("truncated stream: expecting ErrTruncated, got %v", err)
+	}
+}
@@ -1,12 +1,14 @@
 package gfc
 
 type (
-	Encoding  uint8
-	Algorithm uint8
-	AlgoMode  uint8
+	Encoding    uint8
+	Compression uint8
+	Algorithm   uint8
+	AlgoMode    uint8
 )
 
-// Avoid collisions by declaring them in 1 block
+// Avoid collisions by declaring them in 1 block.
+// Library users may persist these values, so new constants are only appended to the block.
 const (
 	AlgoInvalid Algorithm = iota
 	AlgoAES
@@ -23,4 +25,82 @@ const (
 	EncodingNone Encoding = iota
 	EncodingBase64
 	EncodingHex
+
+	AlgoX25519 Algorithm = iota
+	AlgoMulti
+
+	ModeRsaHybrid AlgoMode = iota
+	ModeX25519
+	ModeMultiRecipient
+	ModeAesCTRLegacy
+
+	EncodingArmor     Encoding = iota
+	EncodingBase64URL          // URL-safe base64 without padding
+	EncodingBase64Raw          // Standard base64 without padding
+	EncodingBase32
+	EncodingBase32Raw // Base32 without padding
+	EncodingBase58
+	EncodingAscii85
+	EncodingZ85
+
+	CompressionNone Compression = iota
+	CompressionZstd
+	CompressionGzip
+	CompressionS2
+	CompressionSnappy
 )
+
+// Algorithm returns the algorithm family of mode
+func (mode AlgoMode) Algorithm() Algorithm {
+	switch mode {
+	case ModeAesGCM, ModeAesCTR, ModeAesCTRLegacy:
+		return AlgoAES
+
+	case ModeRsaOEAP, ModeRsaHybrid:
+		return AlgoRSA
+
+	case ModeXChaCha20Poly1305, ModeChaCha20Poly1305:
+		return AlgoXChaCha20
+
+	case ModeX25519:
+		return AlgoX25519
+
+	case ModeMultiRecipient:
+		return AlgoMulti
+	}
+
+	return AlgoInvalid
+}
+
+func (mode AlgoMode) String() string {
+	switch mode {
+	case ModeAesGCM:
+		return "AES256-GCM"
+
+	case ModeAesCTR:
+		return "AES256-CTR-HMAC-SHA256"
+
+	case ModeAesCTRLegacy:
+		return "AES256-CTR (unauthenticated)"
+
+	case ModeRsaOEAP:
+		return "RSA256-OEAP"
+
+	case ModeXChaCha20Poly1305:
+		return "XChaCha20-Poly1305"
+
+	case ModeChaCha20Poly1305:
+		return "ChaCha20-Poly1305"
+
+	case ModeRsaHybrid:
+		return "RSA256-OEAP-Hybrid"
+
+	case ModeX25519:
+		return "X25519-Hybrid"
+
+	case ModeMultiRecipient:
+		return "Multi-Recipient"
+	}
+
+	return "invalid mode"
+}
@@ -21,8 +21,8 @@ func TestCryptography(t *testing.T) {
 	})
 
 	t.Run("testRSA", func(t *testing.T) {
-		pubFile := "./assets/files/pub.pem"
-		priFile := "./assets/files/pri.pem"
+		pubFile := "../../assets/files/pub.pem"
+		priFile := "../../assets/files/pri.pem"
 
 		pubPEM, err := os.ReadFile(pubFile)
 		if err != nil {
@@ -36,6 +36,7 @@ func TestCryptography(t *testing.T) {
 		}
 
 		testAsymmetricCryptograhy(t, "RSA256-OEAP", EncryptRSA, DecryptRSA, plaintext, priPEM, pubPEM)
+		testAsymmetricCryptograhy(t, "RSA256-OEAP-Hybrid", EncryptRSAHybrid, DecryptRSAHybrid, plaintext, priPEM, pubPEM)
 	})
 
 	t.Run("testXChaCha20Poly1305", func(t *testing.T) {
@@ -49,11 +50,31 @@ func TestCryptography(t *testing.T) {
 	})
 }
 
+// TestConstantValues checks that exported constants keep their values from older gfc
+func TestConstantValues(t *testing.T) {
+	values := map[string][2]uint8{
+		"AlgoXChaCha20":        {uint8(AlgoXChaCha20), 3},
+		"ModeInvalid":          {uint8(ModeInvalid), 4},
+		"ModeAesGCM":           {uint8(ModeAesGCM), 5},
+		"ModeAesCTR":           {uint8(ModeAesCTR), 6},
+		"ModeRsaOEAP":          {uint8(ModeRsaOEAP), 7},
+		"ModeChaCha20Poly1305": {uint8(ModeChaCha20Poly1305), 9},
+		"EncodingNone":         {uint8(EncodingNone), 10},
+		"EncodingHex":          {uint8(EncodingHex), 12},
+	}
+
+	for name, value := range values {
+		if value[0] != value[1] {
+			t.Fatalf("%s changed from %d to %d", name, value[1], value[0])
+		}
+	}
+}
+
 func testSymmetricCryptograhy(
 	t *testing.T,
 	name string,
-	encryptFunc func(Buffer, []byte) (Buffer, error),
-	decryptFunc func(Buffer, []byte) (Buffer, error),
+	encryptFunc func(Buffer, []byte, ...Option) (Buffer, error),
+	decryptFunc func(Buffer, []byte, ...Option) (Buffer, error),
 	plaintext []byte,
 	key []byte,
 ) {
@@ -75,8 +96,8 @@ func testSymmetricCryptograhy(
 func testAsymmetricCryptograhy(
 	t *testing.T,
 	name string,
-	encryptFunc func(Buffer, []byte) (Buffer, error),
-	decryptFunc func(Buffer, []byte) (Buffer, error),
+	encryptFunc func(Buffer, []byte, ...Option) (Buffer, error),
+	decryptFunc func(Buffer, []byte, ...Option) (Buffer, error),
 	plaintext []byte,
 	priKey []byte,
 	pubKey []byte,
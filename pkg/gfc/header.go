@@ -0,0 +1,439 @@
+package gfc
+
+// This file provides gfc file header.
+// Every gfc output starts with a header, which describes how the ciphertext
+// was produced, so that decryption does not depend on the user remembering
+// the exact algorithm, mode, KDF, and flags used during encryption.
+//
+// Header layout:
+//
+//	<Magic (4 bytes)> <Version (1 byte)> <Body length (2 bytes)> <Body>
+//
+// Header body is a sequence of fields, each encoded as:
+//
+//	<Tag (1 byte)> <Value length (2 bytes)> <Value>
+//
+// All integers are big-endian. The serialized header is authenticated
+// by the cipher (e.g. as AEAD additional data), so it cannot be tampered with.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	headerVersion  uint8 = 1
+	lenHeaderFixed int   = 4 + 1 + 2 // Magic, version, and body length
+	lenFieldFixed  int   = 1 + 2     // Tag and value length
+)
+
+// headerMagic starts with a non-ASCII byte, so gfc binary output
+// cannot be mistaken for text (e.g. hex or base64-encoded output).
+var headerMagic = []byte{0x89, 'G', 'F', 'C'}
+
+// Header field tags
+const (
+	tagAlgorithm uint8 = iota + 1
+	tagMode
+	tagFlags
+	tagEncoding
+	tagKDF
+	tagSalt
+	tagNonce
+	tagChunkSize
+	tagPayload // AEAD mode of hybrid payload
+	tagStanza  // Recipient stanza of hybrid output, may be repeated
+
+	// Compression algorithm, if not zstd. Older gfc only had zstd, and only set FlagCompressed,
+	// so zstd output still omits this field, and can be decrypted by older gfc.
+	tagCompression
+
+	tagFileSalt // Salt of per-file subkey of symmetric key output (see fileSubkey)
+)
+
+type headerField struct {
+	tag   uint8
+	value []byte
+}
+
+// Flag records pre-processing done on the plaintext before encryption
+type Flag uint8
+
+const (
+	FlagCompressed Flag = 1 << iota // Plaintext was compressed before encryption, see Header.Compression
+	FlagArchive                     // Plaintext is a tar archive of a directory (see archive.go)
+	FlagAAD                         // Ciphertext is bound to associated data not stored in the output (see aad.go)
+	FlagMetadata                    // Plaintext starts with encrypted metadata (see metadata.go)
+)
+
+// Algorithms, modes, and encodings are written to files with these values,
+// because the Go constants in gfc.go shift whenever new constants are added.
+var (
+	wireAlgorithms = map[Algorithm]uint8{
+		AlgoAES:       1,
+		AlgoRSA:       2,
+		AlgoXChaCha20: 3,
+		AlgoX25519:    4,
+		AlgoMulti:     5,
+	}
+
+	wireModes = map[AlgoMode]uint8{
+		ModeAesGCM:            1,
+		ModeAesCTRLegacy:      2, // Unauthenticated AES256-CTR, decrypt-only
+		ModeRsaOEAP:           3,
+		ModeXChaCha20Poly1305: 4,
+		ModeChaCha20Poly1305:  5,
+		ModeRsaHybrid:         6,
+		ModeX25519:            7,
+		ModeMultiRecipient:    8,
+		ModeAesCTR:            9, // AES256-CTR with HMAC-SHA256
+	}
+
+	wireEncodings = map[Encoding]uint8{
+		EncodingNone:      0,
+		EncodingBase64:    1,
+		EncodingHex:       2,
+		EncodingArmor:     3,
+		EncodingBase64URL: 4,
+		EncodingBase64Raw: 5,
+		EncodingBase32:    6,
+		EncodingBase32Raw: 7,
+		EncodingBase58:    8,
+		EncodingAscii85:   9,
+		EncodingZ85:       10,
+	}
+
+	wireCompressions = map[Compression]uint8{
+		CompressionZstd:   1,
+		CompressionGzip:   2,
+		CompressionS2:     3,
+		CompressionSnappy: 4,
+	}
+)
+
+// Header describes how a gfc ciphertext was produced
+type Header struct {
+	Algorithm Algorithm
+	Mode      AlgoMode
+	Flags     Flag
+	Encoding  Encoding // Encoding applied to the output after encryption
+	KDF       KDFParams
+	Salt      []byte
+	Nonce     []byte // Nonce prefix for chunked output
+	ChunkSize uint32 // Plaintext chunk size for chunked output, 0 if not chunked
+
+	// Compression applied to the plaintext before encryption, only meaningful with FlagCompressed.
+	// Marshaled header records it only if it is not zstd, as written by older gfc.
+	Compression Compression
+
+	// Symmetric key modes only. Salt of per-file subkey derived from the key (see fileSubkey),
+	// missing in output written before subkeys were introduced.
+	FileSalt []byte
+
+	// Hybrid modes only (see hybrid.go)
+	Payload AlgoMode // AEAD mode used to encrypt the payload with file key
+	Stanzas []Stanza // Recipient stanzas, each wrapping the file key
+}
+
+func newHeader(mode AlgoMode, o *options) *Header {
+	flags := o.flags
+	if len(o.aad) != 0 {
+		flags |= FlagAAD
+	}
+
+	if o.compression != CompressionNone {
+		flags |= FlagCompressed
+	}
+
+	return &Header{
+		Algorithm:   mode.Algorithm(),
+		Mode:        mode,
+		Flags:       flags,
+		Encoding:    o.encoding,
+		Compression: o.compression,
+	}
+}
+
+// hasHeader reports whether b starts with gfc header magic.
+// Files written by older versions of gfc do not have header.
+func hasHeader(b []byte) bool {
+	return bytes.HasPrefix(b, headerMagic)
+}
+
+// ParseHeader parses gfc header at the start of b
+func ParseHeader(b []byte) (*Header, error) {
+	hdr, _, err := parseHeader(b)
+	return hdr, err
+}
+
+// marshal serializes h into its binary form
+func (h *Header) marshal() ([]byte, error) {
+	algo, ok := wireAlgorithms[h.Algorithm]
+	if !ok {
+		return nil, errors.Wrapf(ErrMarshalHeader, "invalid algorithm %d", h.Algorithm)
+	}
+
+	mode, ok := wireModes[h.Mode]
+	if !ok || h.Mode.Algorithm() != h.Algorithm {
+		return nil, errors.Wrapf(ErrMarshalHeader, "invalid mode %d for algorithm %d", h.Mode, h.Algorithm)
+	}
+
+	encoding, ok := wireEncodings[h.Encoding]
+	if !ok {
+		return nil, errors.Wrapf(ErrMarshalHeader, "invalid encoding %d", h.Encoding)
+	}
+
+	fields := []headerField{
+		{tag: tagAlgorithm, value: []byte{algo}},
+		{tag: tagMode, value: []byte{mode}},
+		{tag: tagFlags, value: []byte{byte(h.Flags)}},
+		{tag: tagEncoding, value: []byte{encoding}},
+		{tag: tagKDF, value: h.KDF.marshal()},
+		{tag: tagSalt, value: h.Salt},
+		{tag: tagNonce, value: h.Nonce},
+	}
+
+	if h.ChunkSize != 0 {
+		fields = append(fields, headerField{tag: tagChunkSize, value: binary.BigEndian.AppendUint32(nil, h.ChunkSize)})
+	}
+
+	if h.Flags&FlagCompressed != 0 {
+		switch h.Compression {
+		case CompressionGzip, CompressionS2, CompressionSnappy:
+			fields = append(fields, headerField{tag: tagCompression, value: []byte{wireCompressions[h.Compression]}})
+		}
+	}
+
+	if len(h.FileSalt) != 0 {
+		fields = append(fields, headerField{tag: tagFileSalt, value: h.FileSalt})
+	}
+
+	if isHybridMode(h.Mode) {
+		payload, ok := wireModes[h.Payload]
+		if !ok || !isStreamMode(h.Payload) {
+			return nil, errors.Wrapf(ErrMarshalHeader, "invalid payload mode %s", h.Payload)
+		}
+
+		if len(h.Stanzas) == 0 {
+			return nil, errors.Wrap(ErrMarshalHeader, "missing recipient stanza")
+		}
+
+		fields = append(fields, headerField{tag: tagPayload, value: []byte{payload}})
+		for _, stanza := range h.Stanzas {
+			fields = append(fields, headerField{tag: tagStanza, value: stanza.marshal()})
+		}
+	}
+
+	body := new(bytes.Buffer)
+	for _, field := range fields {
+		if len(field.value) > math.MaxUint16 {
+			return nil, errors.Wrapf(ErrMarshalHeader, "field %d too long", field.tag)
+		}
+
+		body.WriteByte(field.tag)
+		body.Write(binary.BigEndian.AppendUint16(nil, uint16(len(field.value))))
+		body.Write(field.value)
+	}
+
+	if body.Len() > math.MaxUint16 {
+		return nil, errors.Wrapf(ErrMarshalHeader, "header body too long (%d bytes)", body.Len())
+	}
+
+	out := make([]byte, 0, lenHeaderFixed+body.Len())
+	out = append(out, headerMagic...)
+	out = append(out, headerVersion)
+	out = binary.BigEndian.AppendUint16(out, uint16(body.Len()))
+
+	return append(out, body.Bytes()...), nil
+}
+
+// parseHeader parses gfc header at the start of b,
+// returning the header and the length of its serialized form.
+func parseHeader(b []byte) (*Header, int, error) {
+	if !hasHeader(b) {
+		return nil, 0, errors.Wrap(ErrUnmarshalHeader, "missing gfc header magic")
+	}
+
+	if len(b) < lenHeaderFixed {
+		return nil, 0, errors.Wrap(ErrUnmarshalHeader, "header too short")
+	}
+
+	if version := b[len(headerMagic)]; version != headerVersion {
+		return nil, 0, errors.Wrapf(ErrHeaderVersion, "version %d", version)
+	}
+
+	lenHeader := lenHeaderFixed + int(binary.BigEndian.Uint16(b[len(headerMagic)+1:lenHeaderFixed]))
+	if len(b) < lenHeader {
+		return nil, 0, errors.Wrapf(ErrUnmarshalHeader, "header too short: need %d bytes, got %d", lenHeader, len(b))
+	}
+
+	// Missing encoding field means the output was not encoded
+	hdr := &Header{Encoding: EncodingNone, Compression: CompressionNone}
+	seen := make(map[uint8]bool)
+
+	for body := b[lenHeaderFixed:lenHeader]; len(body) > 0; {
+		if len(body) < lenFieldFixed {
+			return nil, 0, errors.Wrap(ErrUnmarshalHeader, "truncated header field")
+		}
+
+		tag := body[0]
+		lenValue := int(binary.BigEndian.Uint16(body[1:lenFieldFixed]))
+		body = body[lenFieldFixed:]
+
+		if len(body) < lenValue {
+			return nil, 0, errors.Wrapf(ErrUnmarshalHeader, "truncated header field %d", tag)
+		}
+
+		if seen[tag] && tag != tagStanza {
+			return nil, 0, errors.Wrapf(ErrUnmarshalHeader, "duplicate header field %d", tag)
+		}
+
+		seen[tag] = true
+		value := body[:lenValue]
+		body = body[lenValue:]
+
+		if err := hdr.unmarshalField(tag, value); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if hdr.Algorithm == AlgoInvalid || hdr.Mode == ModeInvalid {
+		return nil, 0, errors.Wrap(ErrUnmarshalHeader, "missing algorithm or mode")
+	}
+
+	if hdr.Mode.Algorithm() != hdr.Algorithm {
+		return nil, 0, errors.Wrapf(ErrUnmarshalHeader, "mode %s is not valid for algorithm %d", hdr.Mode, hdr.Algorithm)
+	}
+
+	if isHybridMode(hdr.Mode) {
+		if !isStreamMode(hdr.Payload) || len(hdr.Stanzas) == 0 {
+			return nil, 0, errors.Wrapf(ErrUnmarshalHeader, "mode %s requires AEAD payload mode and recipient stanzas", hdr.Mode)
+		}
+
+		if seen[tagFileSalt] {
+			return nil, 0, errors.Wrapf(ErrUnmarshalHeader, "mode %s cannot have file salt", hdr.Mode)
+		}
+	} else if seen[tagPayload] || seen[tagStanza] {
+		return nil, 0, errors.Wrapf(ErrUnmarshalHeader, "mode %s cannot have payload mode or recipient stanzas", hdr.Mode)
+	}
+
+	if seen[tagChunkSize] && !isStreamMode(hdr.payloadMode()) {
+		return nil, 0, errors.Wrapf(ErrUnmarshalHeader, "mode %s cannot be chunked", hdr.Mode)
+	}
+
+	switch compressed := hdr.Flags&FlagCompressed != 0; {
+	case seen[tagCompression] && !compressed:
+		return nil, 0, errors.Wrap(ErrUnmarshalHeader, "compression field without compressed flag")
+
+	// Missing compression field means zstd
+	case compressed && !seen[tagCompression]:
+		hdr.Compression = CompressionZstd
+	}
+
+	return hdr, lenHeader, nil
+}
+
+func (h *Header) unmarshalField(tag uint8, value []byte) error {
+	switch tag {
+	case tagAlgorithm, tagMode, tagFlags, tagEncoding, tagPayload, tagCompression:
+		if len(value) != 1 {
+			return errors.Wrapf(ErrUnmarshalHeader, "bad length %d for header field %d", len(value), tag)
+		}
+	}
+
+	var ok bool
+
+	switch tag {
+	case tagAlgorithm:
+		h.Algorithm, ok = lookupWire(wireAlgorithms, value[0])
+
+	case tagMode:
+		h.Mode, ok = lookupWire(wireModes, value[0])
+
+	case tagPayload:
+		h.Payload, ok = lookupWire(wireModes, value[0])
+
+	case tagEncoding:
+		h.Encoding, ok = lookupWire(wireEncodings, value[0])
+
+	case tagCompression:
+		h.Compression, ok = lookupWire(wireCompressions, value[0])
+
+	case tagFlags:
+		h.Flags, ok = Flag(value[0]), true
+
+	case tagKDF:
+		var err error
+		h.KDF, err = unmarshalKDFParams(value)
+		if err != nil {
+			return err
+		}
+
+		ok = true
+
+	case tagSalt:
+		h.Salt, ok = value, true
+
+	case tagNonce:
+		h.Nonce, ok = value, true
+
+	case tagFileSalt:
+		if len(value) != lenFileSalt {
+			return errors.Wrapf(ErrUnmarshalHeader, "bad length %d for header field %d", len(value), tag)
+		}
+
+		h.FileSalt, ok = value, true
+
+	case tagChunkSize:
+		if len(value) != 4 {
+			return errors.Wrapf(ErrUnmarshalHeader, "bad length %d for header field %d", len(value), tag)
+		}
+
+		h.ChunkSize = binary.BigEndian.Uint32(value)
+		if h.ChunkSize == 0 || h.ChunkSize > maxChunkSize {
+			return errors.Wrapf(ErrUnmarshalHeader, "bad chunk size %d", h.ChunkSize)
+		}
+
+		ok = true
+
+	case tagStanza:
+		stanza, err := unmarshalStanza(value)
+		if err != nil {
+			return err
+		}
+
+		h.Stanzas, ok = append(h.Stanzas, stanza), true
+
+	default:
+		return errors.Wrapf(ErrUnmarshalHeader, "unknown header field %d", tag)
+	}
+
+	if !ok {
+		return errors.Wrapf(ErrUnmarshalHeader, "bad value %d for header field %d", value[0], tag)
+	}
+
+	return nil
+}
+
+// payloadMode returns the mode used to encrypt the payload of h
+func (h *Header) payloadMode() AlgoMode {
+	if isHybridMode(h.Mode) {
+		return h.Payload
+	}
+
+	return h.Mode
+}
+
+func lookupWire[T comparable](table map[T]uint8, wire uint8) (T, bool) {
+	for value, w := range table {
+		if w == wire {
+			return value, true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
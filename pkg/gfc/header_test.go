@@ -0,0 +1,131 @@
+package gfc
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestHeader(t *testing.T) {
+	hdr := &Header{
+		Algorithm: AlgoAES,
+		Mode:      ModeAesCTR,
+		Flags:     FlagCompressed,
+		Encoding:  EncodingHex,
+		KDF:       defaultKDFParams(),
+		Salt:      bytes.Repeat([]byte{1}, lenPBKDF2Salt),
+		Nonce:     bytes.Repeat([]byte{2}, blockSizeAES256CTR),
+		FileSalt:  bytes.Repeat([]byte{3}, lenFileSalt),
+	}
+
+	b, err := hdr.marshal()
+	if err != nil {
+		t.Fatalf("failed to marshal header: %s", err.Error())
+	}
+
+	parsed, lenHeader, err := parseHeader(append(b, "trailing ciphertext"...))
+	if err != nil {
+		t.Fatalf("failed to parse header: %s", err.Error())
+	}
+
+	if lenHeader != len(b) {
+		t.Fatalf("unexpected header length - expecting %d, got %d", len(b), lenHeader)
+	}
+
+	if parsed.Algorithm != hdr.Algorithm || parsed.Mode != hdr.Mode || parsed.Flags != hdr.Flags ||
+		parsed.Encoding != hdr.Encoding || parsed.KDF != hdr.KDF ||
+		!bytes.Equal(parsed.Salt, hdr.Salt) || !bytes.Equal(parsed.Nonce, hdr.Nonce) ||
+		!bytes.Equal(parsed.FileSalt, hdr.FileSalt) {
+		t.Fatalf("unexpected parsed header - expecting %+v, got %+v", hdr, parsed)
+	}
+
+	b[len(headerMagic)] = headerVersion + 1
+	if _, _, err := parseHeader(b); !errors.Is(err, ErrHeaderVersion) {
+		t.Fatalf("expecting ErrHeaderVersion, got %v", err)
+	}
+}
+
+func TestHeaderMode(t *testing.T) {
+	key := make([]byte, aes256BitKeyFileLen)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("error filling random key bytes: %s", err.Error())
+	}
+
+	ciphertext, err := EncryptCTR(bytes.NewBufferString("foo"), key)
+	if err != nil {
+		t.Fatalf("error encrypting with AES256-CTR: %s", err.Error())
+	}
+
+	if _, err := DecryptGCM(ciphertext, key); !errors.Is(err, ErrHeaderMode) {
+		t.Fatalf("expecting ErrHeaderMode, got %v", err)
+	}
+}
+
+func TestHeaderTampered(t *testing.T) {
+	key := make([]byte, aes256BitKeyFileLen)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("error filling random key bytes: %s", err.Error())
+	}
+
+	ciphertext, err := EncryptGCM(bytes.NewBufferString("foo"), key)
+	if err != nil {
+		t.Fatalf("error encrypting with AES256-GCM: %s", err.Error())
+	}
+
+	// Flip compression flag, which is authenticated as additional data
+	hdr, lenHeader, err := parseHeader(ciphertext.Bytes())
+	if err != nil {
+		t.Fatalf("failed to parse header: %s", err.Error())
+	}
+
+	hdr.Flags ^= FlagCompressed
+	tampered, err := hdr.marshal()
+	if err != nil {
+		t.Fatalf("failed to marshal header: %s", err.Error())
+	}
+
+	tampered = append(tampered, ciphertext.Bytes()[lenHeader:]...)
+	if _, err := DecryptGCM(bytes.NewBuffer(tampered), key); err == nil {
+		t.Fatal("expecting error decrypting tampered header")
+	}
+}
+
+func TestDecryptLegacy(t *testing.T) {
+	plaintext := []byte("this is my legacy plaintext")
+	key := make([]byte, aes256BitKeyFileLen)
+	nonce := make([]byte, lenNonceAESGCM256)
+	salt := make([]byte, lenPBKDF2Salt)
+
+	for _, b := range [][]byte{key, nonce, salt} {
+		if _, err := rand.Read(b); err != nil {
+			t.Fatalf("error filling random bytes: %s", err.Error())
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("failed to create AES cipher: %s", err.Error())
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("failed to create GCM: %s", err.Error())
+	}
+
+	// <Ciphertext> <Cipher Nonce> <PBKDF2 Salt>
+	legacy := gcm.Seal(nil, nonce, plaintext, nil)
+	legacy = append(legacy, nonce...)
+	legacy = append(legacy, salt...)
+
+	decrypted, err := DecryptGCM(bytes.NewBuffer(legacy), key)
+	if err != nil {
+		t.Fatalf("error decrypting legacy output: %s", err.Error())
+	}
+
+	if !bytes.Equal(decrypted.Bytes(), plaintext) {
+		t.Fatal("output does not match")
+	}
+}
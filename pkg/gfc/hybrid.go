@@ -0,0 +1,240 @@
+package gfc
+
+// This file provides hybrid encryption for gfc public-key modes.
+// A random file key encrypts the payload with an AEAD cipher
+// (AES256-GCM or (X)ChaCha20-Poly1305), and the file key is then wrapped
+// for each recipient in a recipient stanza recorded in the header:
+//
+//	<Header (with recipient stanzas)> <Payload>
+//
+// The payload is chunked like AEAD output (see stream.go), so hybrid modes
+// can encrypt inputs of any size. The serialized header, including all stanzas,
+// is authenticated as additional data of the payload AEAD.
+//
+// Each stanza is encoded as:
+//
+//	<Stanza type (1 byte)> <Stanza body>
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	lenFileKey        int = 32
+	lenWrappedFileKey int = lenFileKey + chacha20poly1305.Overhead
+)
+
+// StanzaType describes how the file key is wrapped in a stanza.
+// StanzaType values are written to files, so existing values must never change.
+type StanzaType uint8
+
+const (
+	StanzaRSA        StanzaType = 1 // RSA-OAEP wrapped file key
+	StanzaX25519     StanzaType = 2 // X25519 ephemeral-static ECDH wrapped file key
+	StanzaPassphrase StanzaType = 3 // Passphrase KDF wrapped file key
+	StanzaKeyfile    StanzaType = 4 // 256-bit keyfile wrapped file key
+)
+
+// Stanza wraps the file key of hybrid output for one recipient
+type Stanza struct {
+	Type StanzaType
+	Body []byte
+}
+
+// Recipient wraps file key into a stanza during encryption.
+// Recipients are created with e.g. RecipientRSA or RecipientPassphrase.
+type Recipient interface {
+	wrap(fileKey []byte) (Stanza, error)
+}
+
+// Identity unwraps file key from a stanza during decryption.
+// Identities are created with e.g. IdentityRSA or IdentityPassphrase.
+type Identity interface {
+	// unwrap returns ErrStanzaNoMatch if the stanza was not wrapped for this identity
+	unwrap(stanza Stanza) ([]byte, error)
+}
+
+func (t StanzaType) String() string {
+	switch t {
+	case StanzaRSA:
+		return "RSA-OAEP"
+
+	case StanzaX25519:
+		return "X25519"
+
+	case StanzaPassphrase:
+		return "passphrase"
+
+	case StanzaKeyfile:
+		return "keyfile"
+	}
+
+	return "unknown stanza type"
+}
+
+func (s Stanza) marshal() []byte {
+	return append([]byte{byte(s.Type)}, s.Body...)
+}
+
+func unmarshalStanza(b []byte) (Stanza, error) {
+	if len(b) < 2 {
+		return Stanza{}, errors.Wrap(ErrUnmarshalHeader, "recipient stanza too short")
+	}
+
+	stanza := Stanza{Type: StanzaType(b[0]), Body: b[1:]}
+	switch stanza.Type {
+	case StanzaRSA, StanzaX25519, StanzaPassphrase, StanzaKeyfile:
+		return stanza, nil
+	}
+
+	return Stanza{}, errors.Wrapf(ErrUnmarshalHeader, "unknown recipient stanza type %d", stanza.Type)
+}
+
+// isHybridMode reports whether mode encrypts payload with a file key wrapped in recipient stanzas
+func isHybridMode(mode AlgoMode) bool {
+	switch mode {
+	case ModeRsaHybrid, ModeX25519, ModeMultiRecipient:
+		return true
+	}
+
+	return false
+}
+
+// hybridPayload returns payload mode for hybrid mode, unless set with WithPayload.
+// X25519 defaults to XChaCha20-Poly1305, and other modes to AES256-GCM.
+func hybridPayload(mode AlgoMode, o *options) AlgoMode {
+	if o.payload != ModeInvalid {
+		return o.payload
+	}
+
+	if mode == ModeX25519 {
+		return ModeXChaCha20Poly1305
+	}
+
+	return ModeAesGCM
+}
+
+func newEncryptWriterHybrid(w io.Writer, mode AlgoMode, recipients []Recipient, o *options) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, errors.Wrap(ErrKeySource, "missing recipients")
+	}
+
+	payload := hybridPayload(mode, o)
+
+	spec, err := aeadSpecFor(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "bad payload mode")
+	}
+
+	if o.chunkSize > maxChunkSize {
+		return nil, errors.Wrapf(ErrStreamChunkSize, "chunk size %d", o.chunkSize)
+	}
+
+	fileKey := make([]byte, lenFileKey)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, errors.Wrapf(ErrRandom, "failed to read random file key: %s", err)
+	}
+
+	hdr := newHeader(mode, o)
+	hdr.Payload = payload
+	hdr.ChunkSize = o.chunkSize
+
+	hdr.Nonce = make([]byte, spec.headerNonceSize(o.chunkSize))
+	if _, err := rand.Read(hdr.Nonce); err != nil {
+		return nil, errors.Wrapf(ErrRandom, "failed to read random nonce: %s", err)
+	}
+
+	for _, r := range recipients {
+		stanza, err := r.wrap(fileKey)
+		if err != nil {
+			return nil, err
+		}
+
+		hdr.Stanzas = append(hdr.Stanzas, stanza)
+	}
+
+	header, err := hdr.marshal()
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s encryption", mode)
+	}
+
+	aead, err := spec.newCipher(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return nil, errors.Wrap(err, "failed to write header")
+	}
+
+	return newAEADWriter(w, aead, hdr, associatedData(header, o)), nil
+}
+
+func newDecryptReaderHybrid(r io.Reader, hdr *Header, aad []byte, identities []Identity) (io.Reader, error) {
+	spec, err := aeadSpecFor(hdr.Payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "bad payload mode")
+	}
+
+	fileKey, err := unwrapFileKey(hdr.Stanzas, identities)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAEADReader(r, spec, hdr, aad, fileKey)
+}
+
+// unwrapFileKey returns the file key from the first stanza unwrapped by any of identities
+func unwrapFileKey(stanzas []Stanza, identities []Identity) ([]byte, error) {
+	for _, stanza := range stanzas {
+		for _, id := range identities {
+			fileKey, err := id.unwrap(stanza)
+			if errors.Is(err, ErrStanzaNoMatch) {
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			if len(fileKey) != lenFileKey {
+				return nil, errors.Wrapf(ErrUnwrapFileKey, "bad file key length %d", len(fileKey))
+			}
+
+			return fileKey, nil
+		}
+	}
+
+	return nil, errors.Wrapf(ErrNoRecipient, "none of %d recipient stanzas matched", len(stanzas))
+}
+
+// sealFileKey wraps file key with ChaCha20-Poly1305. Zero nonce is safe,
+// because callers derive a new wrapKey for each stanza, e.g. with random salt.
+func sealFileKey(wrapKey, fileKey []byte) ([]byte, error) {
+	aead, err := newCipherChaCha20(chacha20poly1305.New)(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), fileKey, nil), nil
+}
+
+// openFileKey unwraps file key sealed with sealFileKey.
+// Wrong wrapKey is reported as ErrStanzaNoMatch.
+func openFileKey(wrapKey, wrapped []byte) ([]byte, error) {
+	aead, err := newCipherChaCha20(chacha20poly1305.New)(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	fileKey, err := aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), wrapped, nil)
+	if err != nil {
+		return nil, ErrStanzaNoMatch
+	}
+
+	return fileKey, nil
+}
@@ -0,0 +1,95 @@
+package gfc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestRSAHybrid(t *testing.T) {
+	pubPEM, err := os.ReadFile("../../assets/files/pub.pem")
+	if err != nil {
+		t.Skipf("failed to read public key file: %s", err.Error())
+	}
+
+	priPEM, err := os.ReadFile("../../assets/files/pri.pem")
+	if err != nil {
+		t.Skipf("failed to read private key file: %s", err.Error())
+	}
+
+	// Much longer than what RSA-OEAP can encrypt directly
+	plaintext := make([]byte, 3*int(testChunkSize)+7)
+	if _, err := rand.Read(plaintext); err != nil {
+		t.Fatalf("error filling random plaintext bytes: %s", err.Error())
+	}
+
+	for _, payload := range []AlgoMode{ModeAesGCM, ModeXChaCha20Poly1305} {
+		ciphertext := new(bytes.Buffer)
+
+		w, err := NewEncryptWriter(ciphertext, ModeRsaHybrid, pubPEM, WithPayload(payload), WithChunkSize(testChunkSize))
+		if err != nil {
+			t.Fatalf("%s: error creating encrypt writer: %s", payload, err.Error())
+		}
+
+		if _, err := w.Write(plaintext); err != nil {
+			t.Fatalf("%s: error writing plaintext: %s", payload, err.Error())
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatalf("%s: error closing encrypt writer: %s", payload, err.Error())
+		}
+
+		hdr, err := ParseHeader(ciphertext.Bytes())
+		if err != nil {
+			t.Fatalf("%s: failed to parse header: %s", payload, err.Error())
+		}
+
+		if hdr.Payload != payload || len(hdr.Stanzas) != 1 || hdr.Stanzas[0].Type != StanzaRSA {
+			t.Fatalf("%s: unexpected header %+v", payload, hdr)
+		}
+
+		decrypted := decryptStream(t, ciphertext.Bytes(), priPEM)
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatalf("%s: output does not match", payload)
+		}
+	}
+
+	ciphertext, err := EncryptRSAHybrid(bytes.NewBuffer(plaintext), pubPEM)
+	if err != nil {
+		t.Fatalf("error encrypting: %s", err.Error())
+	}
+
+	t.Run("wrong key", func(t *testing.T) {
+		pri, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("failed to generate RSA key: %s", err.Error())
+		}
+
+		otherPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(pri)})
+
+		_, err = NewDecryptReader(bytes.NewReader(ciphertext.Bytes()), otherPEM)
+		if !errors.Is(err, ErrNoRecipient) {
+			t.Fatalf("expecting ErrNoRecipient, got %v", err)
+		}
+	})
+
+	t.Run("tampered payload", func(t *testing.T) {
+		tampered := append([]byte{}, ciphertext.Bytes()...)
+		tampered[len(tampered)-1] ^= 1
+
+		r, err := NewDecryptReader(bytes.NewReader(tampered), priPEM)
+		if err == nil {
+			_, err = io.ReadAll(r)
+		}
+
+		if err == nil {
+			t.Fatal("unexpected nil error for tampered payload")
+		}
+	})
+}
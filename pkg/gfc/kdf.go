@@ -0,0 +1,280 @@
+package gfc
+
+// This file defines the key derivation functions (KDFs) known to gfc,
+// and how their parameters are recorded in the gfc header.
+// KDF values are written to files, so existing values must never change.
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+)
+
+type (
+	KDF     uint8
+	KDFHash uint8
+)
+
+const (
+	KDFNone     KDF = 0 // Raw 256-bit keyfile, no key derivation
+	KDFPBKDF2   KDF = 1
+	KDFArgon2id KDF = 2
+	KDFScrypt   KDF = 3
+)
+
+// Default Argon2id parameters, following the second recommended option of RFC 9106
+const (
+	argon2idTime        uint32 = 3
+	argon2idMemory      uint32 = 64 << 10 // 64 MiB
+	argon2idParallelism uint8  = 4
+	maxArgon2idMemory   uint32 = 4 << 20 // 4 GiB
+	maxArgon2idTime     uint32 = 64
+)
+
+// Default scrypt parameters, following the recommendation for interactive logins
+const (
+	scryptN         uint32 = 1 << 15
+	scryptR         uint32 = 8
+	scryptP         uint32 = 1
+	maxScryptMemory uint64 = 4 << 30 // 4 GiB
+	maxScryptCost   uint64 = 1 << 26 // Limit of N*r*p, 256 times the default
+)
+
+// maxPBKDF2Iterations limits PBKDF2 iterations, 64 times the default
+const maxPBKDF2Iterations uint32 = 1 << 26
+
+const (
+	KDFHashSHA256 KDFHash = 1
+	KDFHashSHA512 KDFHash = 2
+)
+
+// KDFParams describes a KDF and the parameters used to derive a key with it
+type KDFParams struct {
+	KDF KDF
+
+	// PBKDF2
+	Iterations uint32
+	Hash       KDFHash
+
+	// Argon2id
+	Time        uint32
+	Memory      uint32 // In KiB
+	Parallelism uint8
+
+	// scrypt
+	N uint32 // CPU/memory cost, a power of 2
+	R uint32 // Block size
+	P uint32 // Parallelization
+}
+
+func (kdf KDF) String() string {
+	switch kdf {
+	case KDFNone:
+		return "none"
+
+	case KDFPBKDF2:
+		return "PBKDF2"
+
+	case KDFArgon2id:
+		return "Argon2id"
+
+	case KDFScrypt:
+		return "scrypt"
+	}
+
+	return "unknown KDF"
+}
+
+func (hash KDFHash) String() string {
+	switch hash {
+	case KDFHashSHA256:
+		return "SHA-256"
+
+	case KDFHashSHA512:
+		return "SHA-512"
+	}
+
+	return "unknown KDF hash"
+}
+
+// DefaultKDFParams returns the recommended parameters for kdf.
+// Unknown KDFs return parameters for KDFNone.
+func DefaultKDFParams(kdf KDF) KDFParams {
+	switch kdf {
+	case KDFPBKDF2:
+		return KDFParams{
+			KDF:        KDFPBKDF2,
+			Iterations: uint32(pbkdf2Rounds),
+			Hash:       KDFHashSHA256,
+		}
+
+	case KDFArgon2id:
+		return KDFParams{
+			KDF:         KDFArgon2id,
+			Time:        argon2idTime,
+			Memory:      argon2idMemory,
+			Parallelism: argon2idParallelism,
+		}
+
+	case KDFScrypt:
+		return KDFParams{
+			KDF: KDFScrypt,
+			N:   scryptN,
+			R:   scryptR,
+			P:   scryptP,
+		}
+	}
+
+	return KDFParams{KDF: KDFNone}
+}
+
+// defaultKDFParams returns the KDF parameters used by the library for new passphrase encryption
+func defaultKDFParams() KDFParams {
+	return DefaultKDFParams(KDFPBKDF2)
+}
+
+// deriveKey derives a 256-bit key from passphrase and salt
+func (p KDFParams) deriveKey(passphrase, salt []byte) ([]byte, error) {
+	switch p.KDF {
+	case KDFPBKDF2:
+		return keyPBKDF2(passphrase, salt, p)
+
+	case KDFArgon2id:
+		return keyArgon2id(passphrase, salt, p)
+
+	case KDFScrypt:
+		return keyScrypt(passphrase, salt, p)
+	}
+
+	return nil, errors.Wrapf(ErrKDFParams, "unsupported KDF %d", p.KDF)
+}
+
+// Validate checks that p can be used to derive key, e.g. that scrypt N is a power of 2,
+// and that the memory needed is within the limits gfc accepts when decrypting.
+func (p KDFParams) Validate() error {
+	switch p.KDF {
+	case KDFNone:
+		return nil
+
+	case KDFPBKDF2:
+		return p.validatePBKDF2()
+
+	case KDFArgon2id:
+		return p.validateArgon2id()
+
+	case KDFScrypt:
+		return p.validateScrypt()
+	}
+
+	return errors.Wrapf(ErrKDFParams, "unsupported KDF %d", p.KDF)
+}
+
+// marshal serializes p into the value of header field tagKDF:
+//
+//	PBKDF2:   <KDF (1 byte)> <Iterations (4 bytes)> <Hash (1 byte)>
+//	Argon2id: <KDF (1 byte)> <Time (4 bytes)> <Memory in KiB (4 bytes)> <Parallelism (1 byte)>
+//	scrypt:   <KDF (1 byte)> <N (4 bytes)> <r (4 bytes)> <p (4 bytes)>
+func (p KDFParams) marshal() []byte {
+	switch p.KDF {
+	case KDFPBKDF2:
+		b := []byte{byte(p.KDF)}
+		b = binary.BigEndian.AppendUint32(b, p.Iterations)
+
+		return append(b, byte(p.Hash))
+
+	case KDFArgon2id:
+		b := []byte{byte(p.KDF)}
+		b = binary.BigEndian.AppendUint32(b, p.Time)
+		b = binary.BigEndian.AppendUint32(b, p.Memory)
+
+		return append(b, p.Parallelism)
+
+	case KDFScrypt:
+		b := []byte{byte(p.KDF)}
+		b = binary.BigEndian.AppendUint32(b, p.N)
+		b = binary.BigEndian.AppendUint32(b, p.R)
+
+		return binary.BigEndian.AppendUint32(b, p.P)
+	}
+
+	return []byte{byte(p.KDF)}
+}
+
+func unmarshalKDFParams(b []byte) (KDFParams, error) {
+	if len(b) == 0 {
+		return KDFParams{}, errors.Wrap(ErrUnmarshalHeader, "empty KDF field")
+	}
+
+	p := KDFParams{KDF: KDF(b[0])}
+	b = b[1:]
+
+	switch p.KDF {
+	case KDFNone:
+		if len(b) != 0 {
+			return KDFParams{}, errors.Wrap(ErrUnmarshalHeader, "unexpected parameters for KDF none")
+		}
+
+	case KDFPBKDF2:
+		if len(b) != 5 {
+			return KDFParams{}, errors.Wrapf(ErrUnmarshalHeader, "bad PBKDF2 parameters length %d", len(b))
+		}
+
+		p.Iterations = binary.BigEndian.Uint32(b[:4])
+		p.Hash = KDFHash(b[4])
+
+	case KDFArgon2id:
+		if len(b) != 9 {
+			return KDFParams{}, errors.Wrapf(ErrUnmarshalHeader, "bad Argon2id parameters length %d", len(b))
+		}
+
+		p.Time = binary.BigEndian.Uint32(b[:4])
+		p.Memory = binary.BigEndian.Uint32(b[4:8])
+		p.Parallelism = b[8]
+
+	case KDFScrypt:
+		if len(b) != 12 {
+			return KDFParams{}, errors.Wrapf(ErrUnmarshalHeader, "bad scrypt parameters length %d", len(b))
+		}
+
+		p.N = binary.BigEndian.Uint32(b[:4])
+		p.R = binary.BigEndian.Uint32(b[4:8])
+		p.P = binary.BigEndian.Uint32(b[8:12])
+
+	default:
+		return KDFParams{}, errors.Wrapf(ErrUnmarshalHeader, "unknown KDF %d", p.KDF)
+	}
+
+	return p, nil
+}
+
+// keyArgon2id derives 256-bit key from passphrase and salt using Argon2id with params.
+// Parameters are checked first, since they may come from an untrusted header.
+func keyArgon2id(passphrase, salt []byte, params KDFParams) ([]byte, error) {
+	if err := params.validateArgon2id(); err != nil {
+		return nil, err
+	}
+
+	return argon2.IDKey(passphrase, salt, params.Time, params.Memory, params.Parallelism, uint32(aes256BitKeyFileLen)), nil
+}
+
+func (p KDFParams) validateArgon2id() error {
+	switch {
+	case p.Time == 0:
+		return errors.Wrap(ErrKDFParams, "zero Argon2id time")
+
+	case p.Time > maxArgon2idTime:
+		return errors.Wrapf(ErrKDFParams, "Argon2id time %d exceeds limit %d", p.Time, maxArgon2idTime)
+
+	case p.Parallelism == 0:
+		return errors.Wrap(ErrKDFParams, "zero Argon2id parallelism")
+
+	case p.Memory < 8*uint32(p.Parallelism):
+		return errors.Wrapf(ErrKDFParams, "Argon2id memory %d KiB too small for parallelism %d", p.Memory, p.Parallelism)
+
+	case p.Memory > maxArgon2idMemory:
+		return errors.Wrapf(ErrKDFParams, "Argon2id memory %d KiB exceeds limit %d KiB", p.Memory, maxArgon2idMemory)
+	}
+
+	return nil
+}
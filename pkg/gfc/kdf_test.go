@@ -0,0 +1,157 @@
+package gfc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestKDFParams(t *testing.T) {
+	for _, kdf := range []KDF{KDFNone, KDFPBKDF2, KDFArgon2id, KDFScrypt} {
+		params := DefaultKDFParams(kdf)
+
+		parsed, err := unmarshalKDFParams(params.marshal())
+		if err != nil {
+			t.Fatalf("%s: failed to unmarshal KDF parameters: %s", kdf, err.Error())
+		}
+
+		if parsed != params {
+			t.Fatalf("%s: unexpected KDF parameters - expecting %+v, got %+v", kdf, params, parsed)
+		}
+	}
+}
+
+func TestKDFArgon2id(t *testing.T) {
+	passphrase := []byte("my passphrase")
+	salt := bytes.Repeat([]byte{1}, lenPBKDF2Salt)
+	params := KDFParams{KDF: KDFArgon2id, Time: 1, Memory: 64, Parallelism: 1}
+
+	key, err := params.deriveKey(passphrase, salt)
+	if err != nil {
+		t.Fatalf("failed to derive key: %s", err.Error())
+	}
+
+	if len(key) != aes256BitKeyFileLen {
+		t.Fatalf("unexpected key length %d", len(key))
+	}
+
+	other, _ := params.deriveKey(passphrase, salt)
+	if !bytes.Equal(key, other) {
+		t.Fatal("Argon2id is not deterministic")
+	}
+
+	bad := map[string]KDFParams{
+		"zero time":        {KDF: KDFArgon2id, Time: 0, Memory: 64, Parallelism: 1},
+		"huge time":        {KDF: KDFArgon2id, Time: 1 << 31, Memory: maxArgon2idMemory, Parallelism: 255},
+		"zero parallelism": {KDF: KDFArgon2id, Time: 1, Memory: 64, Parallelism: 0},
+		"small memory":     {KDF: KDFArgon2id, Time: 1, Memory: 8, Parallelism: 4},
+		"huge memory":      {KDF: KDFArgon2id, Time: 1, Memory: maxArgon2idMemory + 1, Parallelism: 1},
+	}
+
+	for name, params := range bad {
+		if _, err := params.deriveKey(passphrase, salt); !errors.Is(err, ErrKDFParams) {
+			t.Fatalf("%s: expecting ErrKDFParams, got %v", name, err)
+		}
+	}
+}
+
+func TestKDFScrypt(t *testing.T) {
+	passphrase := []byte("my passphrase")
+	salt := bytes.Repeat([]byte{1}, lenPBKDF2Salt)
+	params := KDFParams{KDF: KDFScrypt, N: 1 << 4, R: 8, P: 1}
+
+	key, err := params.deriveKey(passphrase, salt)
+	if err != nil {
+		t.Fatalf("failed to derive key: %s", err.Error())
+	}
+
+	if len(key) != aes256BitKeyFileLen {
+		t.Fatalf("unexpected key length %d", len(key))
+	}
+
+	bad := map[string]KDFParams{
+		"N not power of 2": {KDF: KDFScrypt, N: 1000, R: 8, P: 1},
+		"N is 1":           {KDF: KDFScrypt, N: 1, R: 8, P: 1},
+		"zero r":           {KDF: KDFScrypt, N: 1 << 4, R: 0, P: 1},
+		"huge memory":      {KDF: KDFScrypt, N: 1 << 31, R: 8, P: 1},
+		"huge p memory":    {KDF: KDFScrypt, N: 2, R: 1, P: 1 << 29},
+		"huge cost":        {KDF: KDFScrypt, N: 1 << 20, R: 8, P: 16},
+	}
+
+	for name, params := range bad {
+		if _, err := params.deriveKey(passphrase, salt); !errors.Is(err, ErrKDFParams) {
+			t.Fatalf("%s: expecting ErrKDFParams, got %v", name, err)
+		}
+	}
+}
+
+func TestKDFPBKDF2(t *testing.T) {
+	passphrase := []byte("my passphrase")
+	salt := bytes.Repeat([]byte{1}, lenPBKDF2Salt)
+
+	sha256Key, err := KDFParams{KDF: KDFPBKDF2, Iterations: 10, Hash: KDFHashSHA256}.deriveKey(passphrase, salt)
+	if err != nil {
+		t.Fatalf("failed to derive key with SHA-256: %s", err.Error())
+	}
+
+	sha512Key, err := KDFParams{KDF: KDFPBKDF2, Iterations: 10, Hash: KDFHashSHA512}.deriveKey(passphrase, salt)
+	if err != nil {
+		t.Fatalf("failed to derive key with SHA-512: %s", err.Error())
+	}
+
+	if len(sha512Key) != aes256BitKeyFileLen || bytes.Equal(sha256Key, sha512Key) {
+		t.Fatal("unexpected SHA-512 key")
+	}
+
+	params := DefaultKDFParams(KDFPBKDF2)
+	params.Iterations = 10
+	params.Hash = KDFHashSHA512
+
+	parsed, err := unmarshalKDFParams(params.marshal())
+	if err != nil || parsed != params {
+		t.Fatalf("unexpected KDF parameters - expecting %+v, got %+v (%v)", params, parsed, err)
+	}
+
+	bad := map[string]KDFParams{
+		"zero iterations": {KDF: KDFPBKDF2, Iterations: 0, Hash: KDFHashSHA256},
+		"many iterations": {KDF: KDFPBKDF2, Iterations: maxPBKDF2Iterations + 1, Hash: KDFHashSHA256},
+		"unknown hash":    {KDF: KDFPBKDF2, Iterations: 10, Hash: 3},
+	}
+
+	for name, params := range bad {
+		if _, err := params.deriveKey(passphrase, salt); !errors.Is(err, ErrKDFParams) {
+			t.Fatalf("%s: expecting ErrKDFParams, got %v", name, err)
+		}
+	}
+}
+
+func TestWithPassphrase(t *testing.T) {
+	plaintext := []byte("passphrase plaintext")
+	kdf := KDFParams{KDF: KDFArgon2id, Time: 1, Memory: 64, Parallelism: 1}
+
+	ciphertext, err := EncryptXChaCha20Poly1305(bytes.NewBuffer(plaintext), nil, WithKDF(kdf), WithPassphrase([]byte("my passphrase")))
+	if err != nil {
+		t.Fatalf("failed to encrypt: %s", err.Error())
+	}
+
+	raw := ciphertext.Bytes()
+
+	decrypted, err := DecryptXChaCha20Poly1305(bytes.NewBuffer(raw), nil, WithPassphrase([]byte("my passphrase")))
+	if err != nil {
+		t.Fatalf("failed to decrypt: %s", err.Error())
+	}
+
+	if !bytes.Equal(decrypted.Bytes(), plaintext) {
+		t.Fatal("unexpected plaintext")
+	}
+
+	if _, err := DecryptXChaCha20Poly1305(bytes.NewBuffer(raw), nil, WithPassphrase([]byte("wrong passphrase"))); err == nil {
+		t.Fatal("expecting error decrypting with wrong passphrase")
+	}
+
+	// Passphrase is distinct from key, and they cannot be used together
+	key := make([]byte, aes256BitKeyFileLen)
+	if _, err := DecryptXChaCha20Poly1305(bytes.NewBuffer(raw), key, WithPassphrase([]byte("my passphrase"))); !errors.Is(err, ErrKeySource) {
+		t.Fatalf("expecting ErrKeySource, got %v", err)
+	}
+}
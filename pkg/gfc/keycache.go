@@ -0,0 +1,128 @@
+package gfc
+
+// This file provides KeyCache, which lets many files share one passphrase prompt
+// and one KDF run, e.g. when encrypting thousands of files in batch.
+// The cached KDF output is only a master key: every file is encrypted with its own
+// subkey, derived from the master key with a random salt from its header (see fileSubkey).
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// KeyCache caches passphrase and master keys derived from it across encryptions and decryptions.
+// New encryptions with the same KDF parameters share the same KDF salt and master key (but not
+// cipher key), while decryptions derive the master key of each distinct KDF salt only once.
+// The first passphrase used (or prompted for) is used for all operations sharing the cache.
+// KeyCache is safe for concurrent use, and distinct master keys are derived concurrently.
+type KeyCache struct {
+	mu         sync.Mutex
+	passphrase []byte
+	salts      map[KDFParams][]byte      // KDF salt for new encryption with KDF
+	masterKeys map[derivation]*cachedKey // Master keys derived, or being derived
+}
+
+type derivation struct {
+	kdf  KDFParams
+	salt string
+}
+
+// cachedKey is a master key derived only once, by the first operation needing it
+type cachedKey struct {
+	once sync.Once
+	key  []byte
+	err  error
+}
+
+// NewKeyCache returns an empty KeyCache, which prompts for passphrase on first use
+func NewKeyCache() *KeyCache {
+	return &KeyCache{
+		salts:      make(map[KDFParams][]byte),
+		masterKeys: make(map[derivation]*cachedKey),
+	}
+}
+
+// WithKeyCache shares passphrase and derived keys in cache with other operations using cache.
+// Without it, every passphrase encryption or decryption prompts for passphrase and derives its own key.
+func WithKeyCache(cache *KeyCache) Option {
+	return func(o *options) {
+		if cache != nil {
+			o.keys = cache
+		}
+	}
+}
+
+// encryptMasterKey returns KDF salt and master key derived with kdf for new encryption
+func (c *KeyCache) encryptMasterKey(kdf KDFParams, passphrase []byte) ([]byte, []byte, error) {
+	c.mu.Lock()
+	salt, ok := c.salts[kdf]
+	if !ok {
+		var err error
+		if salt, err = newSalt(); err != nil {
+			c.mu.Unlock()
+			return nil, nil, err
+		}
+
+		c.salts[kdf] = salt
+	}
+	c.mu.Unlock()
+
+	key, err := c.masterKey(kdf, salt, passphrase, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return salt, key, nil
+}
+
+// masterKey returns master key derived from passphrase with kdf and salt. If passphrase is nil,
+// the cached passphrase is used, and the user is prompted for it if there is none
+// (twice if confirm is true). The lock is only held for the cache lookup, so that a slow KDF
+// does not block other keys, while the same key is never derived twice.
+func (c *KeyCache) masterKey(kdf KDFParams, salt []byte, passphrase []byte, confirm bool) ([]byte, error) {
+	passphrase, err := c.cachedPassphrase(passphrase, confirm)
+	if err != nil {
+		return nil, err
+	}
+
+	d := derivation{kdf: kdf, salt: string(salt)}
+
+	c.mu.Lock()
+	k, ok := c.masterKeys[d]
+	if !ok {
+		k = new(cachedKey)
+		c.masterKeys[d] = k
+	}
+	c.mu.Unlock()
+
+	k.once.Do(func() {
+		k.key, k.err = kdf.deriveKey(passphrase, salt)
+	})
+
+	if k.err != nil {
+		return nil, errors.Wrap(k.err, ErrPBKDF2KeySalt.Error())
+	}
+
+	return k.key, nil
+}
+
+// cachedPassphrase returns the cached passphrase. If there is none, passphrase is cached,
+// or the user is prompted for it if passphrase is nil.
+func (c *KeyCache) cachedPassphrase(passphrase []byte, confirm bool) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.passphrase == nil {
+		c.passphrase = passphrase
+	}
+
+	if c.passphrase == nil {
+		var err error
+		if c.passphrase, err = getPass(confirm); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.passphrase, nil
+}
@@ -0,0 +1,120 @@
+package gfc
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyCache(t *testing.T) {
+	kdf := KDFParams{KDF: KDFArgon2id, Time: 1, Memory: 64, Parallelism: 1}
+	plaintext := []byte("batch plaintext")
+
+	// Passphrase is set directly, so that the test does not prompt
+	encryptCache := NewKeyCache()
+	encryptCache.passphrase = []byte("batch passphrase")
+
+	var wg sync.WaitGroup
+	ciphertexts := make([][]byte, 8)
+
+	for i := range ciphertexts {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			ciphertext, err := EncryptGCM(bytes.NewBuffer(plaintext), nil, WithKDF(kdf), WithKeyCache(encryptCache))
+			if err != nil {
+				t.Errorf("failed to encrypt: %s", err.Error())
+				return
+			}
+
+			ciphertexts[i] = ciphertext.Bytes()
+		}(i)
+	}
+
+	wg.Wait()
+
+	if n := len(encryptCache.masterKeys); n != 1 {
+		t.Fatalf("expecting key to be derived once, got %d keys", n)
+	}
+
+	// Files share KDF salt and master key, but each has its own subkey
+	fileSalts := make(map[string]bool)
+	for _, ciphertext := range ciphertexts {
+		hdr, err := ParseHeader(ciphertext)
+		if err != nil {
+			t.Fatalf("failed to parse header: %s", err.Error())
+		}
+
+		fileSalts[string(hdr.FileSalt)] = true
+	}
+
+	if len(fileSalts) != len(ciphertexts) {
+		t.Fatalf("expecting %d distinct file salts, got %d", len(ciphertexts), len(fileSalts))
+	}
+
+	decryptCache := NewKeyCache()
+	decryptCache.passphrase = []byte("batch passphrase")
+
+	for _, ciphertext := range ciphertexts {
+		decrypted, err := decryptBuffer(ModeAesGCM, bytes.NewBuffer(ciphertext), nil, []Option{WithKeyCache(decryptCache)})
+		if err != nil {
+			t.Fatalf("failed to decrypt: %s", err.Error())
+		}
+
+		if !bytes.Equal(decrypted.Bytes(), plaintext) {
+			t.Fatal("unexpected plaintext")
+		}
+	}
+
+	if n := len(decryptCache.masterKeys); n != 1 {
+		t.Fatalf("expecting key to be derived once, got %d keys", n)
+	}
+
+	// A cache with wrong passphrase derives a different key
+	wrongCache := NewKeyCache()
+	wrongCache.passphrase = []byte("wrong passphrase")
+
+	if _, err := decryptBuffer(ModeAesGCM, bytes.NewBuffer(ciphertexts[0]), nil, []Option{WithKeyCache(wrongCache)}); err == nil {
+		t.Fatal("expecting error decrypting with wrong passphrase")
+	}
+}
+
+func TestKeyCacheConcurrentKDF(t *testing.T) {
+	kdf := KDFParams{KDF: KDFArgon2id, Time: 1, Memory: 64, Parallelism: 1}
+
+	cache := NewKeyCache()
+	cache.passphrase = []byte("batch passphrase")
+
+	// Simulate a slow KDF run for one salt, which must not block other salts
+	slow := new(cachedKey)
+	cache.masterKeys[derivation{kdf: kdf, salt: "slow salt"}] = slow
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go slow.once.Do(func() {
+		close(started)
+		<-release
+	})
+
+	<-started
+	defer close(release)
+
+	done := make(chan error)
+	go func() {
+		_, err := cache.masterKey(kdf, []byte("other salt"), nil, false)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("failed to derive key: %s", err.Error())
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("key derivation blocked by derivation of another key")
+	}
+}
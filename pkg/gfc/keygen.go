@@ -0,0 +1,117 @@
+package gfc
+
+// This file provides key generation for gfc symmetric keys and RSA keypairs.
+// X25519 and Ed25519 keypairs are generated with GenerateX25519 and GenerateEd25519.
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+)
+
+// KeyFormat is the PEM encoding of generated RSA keys
+type KeyFormat uint8
+
+const (
+	KeyFormatPKCS1 KeyFormat = iota + 1 // PKCS #1, for private or public keys
+	KeyFormatPKCS8                      // PKCS #8, for private keys
+	KeyFormatPKIX                       // PKIX (SubjectPublicKeyInfo), for public keys
+
+	MinBitsRSA = 2048
+	MaxBitsRSA = 16384
+)
+
+func (f KeyFormat) String() string {
+	switch f {
+	case KeyFormatPKCS1:
+		return "PKCS1"
+
+	case KeyFormatPKCS8:
+		return "PKCS8"
+
+	case KeyFormatPKIX:
+		return "PKIX"
+	}
+
+	return "unknown key format"
+}
+
+// GenerateKey generates a new 256-bit keyfile for symmetric encryption
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, aes256BitKeyFileLen)
+	if _, err := rand.Read(key); err != nil {
+		return nil, errors.Wrapf(ErrRandom, "failed to read random key: %s", err)
+	}
+
+	return key, nil
+}
+
+// DecodeKeyfile returns the raw 256-bit key of keyfile, which may also be hex or base64-encoded
+// (e.g. by gfc keygen with encoding) with surrounding whitespace. Other input is returned as it is.
+func DecodeKeyfile(keyfile []byte) []byte {
+	if len(keyfile) == aes256BitKeyFileLen {
+		return keyfile
+	}
+
+	text := bytes.TrimSpace(keyfile)
+	for _, encoding := range []Encoding{EncodingHex, EncodingBase64} {
+		if key, err := Decode(encoding, bytes.NewBuffer(text)); err == nil && key.Len() == aes256BitKeyFileLen {
+			return key.Bytes()
+		}
+	}
+
+	return keyfile
+}
+
+// GenerateRSA generates a new RSA keypair of size bits, and returns the PEM-encoded
+// public and private keys. The private key is encoded as PKCS1 or PKCS8, and the public key as PKCS1 or PKIX.
+func GenerateRSA(bits int, privateFormat, publicFormat KeyFormat) ([]byte, []byte, error) {
+	if bits < MinBitsRSA || bits > MaxBitsRSA {
+		return nil, nil, errors.Wrapf(ErrKeygen, "RSA key size must be between %d and %d bits, got %d", MinBitsRSA, MaxBitsRSA, bits)
+	}
+
+	pri, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, nil, errors.Wrap(ErrKeygen, err.Error())
+	}
+
+	var priBlock *pem.Block
+	switch privateFormat {
+	case KeyFormatPKCS1:
+		priBlock = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(pri)}
+
+	case KeyFormatPKCS8:
+		der, err := x509.MarshalPKCS8PrivateKey(pri)
+		if err != nil {
+			return nil, nil, errors.Wrap(ErrKeygen, err.Error())
+		}
+
+		priBlock = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+
+	default:
+		return nil, nil, errors.Wrapf(ErrKeygen, "bad private key format %s", privateFormat)
+	}
+
+	var pubBlock *pem.Block
+	switch publicFormat {
+	case KeyFormatPKCS1:
+		pubBlock = &pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&pri.PublicKey)}
+
+	case KeyFormatPKIX:
+		der, err := x509.MarshalPKIXPublicKey(&pri.PublicKey)
+		if err != nil {
+			return nil, nil, errors.Wrap(ErrKeygen, err.Error())
+		}
+
+		pubBlock = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+
+	default:
+		return nil, nil, errors.Wrapf(ErrKeygen, "bad public key format %s", publicFormat)
+	}
+
+	return pem.EncodeToMemory(pubBlock), pem.EncodeToMemory(priBlock), nil
+}
@@ -0,0 +1,84 @@
+package gfc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestGenerateKey(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("error generating key: %s", err.Error())
+	}
+
+	if len(key) != aes256BitKeyFileLen {
+		t.Fatalf("unexpected key length %d", len(key))
+	}
+}
+
+func TestGenerateRSA(t *testing.T) {
+	plaintext := []byte("this is my plaintext")
+
+	formats := [][2]KeyFormat{
+		{KeyFormatPKCS1, KeyFormatPKIX},
+		{KeyFormatPKCS8, KeyFormatPKCS1},
+	}
+
+	for _, format := range formats {
+		pub, pri, err := GenerateRSA(MinBitsRSA, format[0], format[1])
+		if err != nil {
+			t.Fatalf("%s/%s: error generating RSA keypair: %s", format[0], format[1], err.Error())
+		}
+
+		ciphertext, err := EncryptRSAHybrid(bytes.NewBuffer(plaintext), pub)
+		if err != nil {
+			t.Fatalf("%s/%s: error encrypting: %s", format[0], format[1], err.Error())
+		}
+
+		decrypted, err := DecryptRSAHybrid(ciphertext, pri)
+		if err != nil {
+			t.Fatalf("%s/%s: error decrypting: %s", format[0], format[1], err.Error())
+		}
+
+		if !bytes.Equal(decrypted.Bytes(), plaintext) {
+			t.Fatalf("%s/%s: output does not match", format[0], format[1])
+		}
+	}
+
+	if _, _, err := GenerateRSA(1024, KeyFormatPKCS1, KeyFormatPKIX); !errors.Is(err, ErrKeygen) {
+		t.Fatalf("short key: expecting ErrKeygen, got %v", err)
+	}
+
+	if _, _, err := GenerateRSA(MinBitsRSA, KeyFormatPKIX, KeyFormatPKIX); !errors.Is(err, ErrKeygen) {
+		t.Fatalf("bad private key format: expecting ErrKeygen, got %v", err)
+	}
+}
+
+func TestDecodeKeyfile(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("error generating key: %s", err.Error())
+	}
+
+	for _, encoding := range []Encoding{EncodingNone, EncodingHex, EncodingBase64} {
+		encoded, err := Encode(encoding, bytes.NewBuffer(key))
+		if err != nil {
+			t.Fatalf("encoding %d: error encoding key: %s", encoding, err.Error())
+		}
+
+		keyfile := encoded.Bytes()
+		if encoding != EncodingNone {
+			keyfile = append(keyfile, '\n')
+		}
+
+		if !bytes.Equal(DecodeKeyfile(keyfile), key) {
+			t.Fatalf("encoding %d: decoded key does not match", encoding)
+		}
+	}
+
+	// Not a keyfile
+	if short := []byte("deadbeef"); !bytes.Equal(DecodeKeyfile(short), short) {
+		t.Fatal("unexpected decoded key")
+	}
+}
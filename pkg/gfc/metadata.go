@@ -0,0 +1,240 @@
+package gfc
+
+// This file provides optional metadata of the original file, e.g. its name and mode,
+// so that decryption can restore it. Unlike the header, metadata is encrypted:
+// it is written as the start of the plaintext, and FlagMetadata is set in the header.
+//
+//	<Metadata length (2 bytes)> <Metadata fields> <Plaintext>
+//
+// Metadata fields are encoded like header fields, i.e. tag, value length, and value.
+// NewDecryptReader strips metadata from the plaintext, see WithReadMetadata.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const lenMetadataFixed = 2 // Metadata length
+
+// Metadata field tags
+const (
+	tagMetadataName uint8 = iota + 1
+	tagMetadataMode
+	tagMetadataModTime
+	tagMetadataSize
+)
+
+// Metadata describes the original file of the plaintext. All fields are optional.
+type Metadata struct {
+	Name    string      // Base name of the original file, or empty if unknown
+	Mode    fs.FileMode // Unix permission bits of the original file
+	ModTime time.Time   // Modification time of the original file, or zero if unknown
+	Size    int64       // Plaintext size (before compression), or -1 if unknown
+}
+
+// MetadataFromFileInfo returns metadata of file with info. Size is unknown for directories.
+func MetadataFromFileInfo(info fs.FileInfo) *Metadata {
+	md := &Metadata{
+		Name:    info.Name(),
+		Mode:    info.Mode().Perm(),
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+	}
+
+	if info.IsDir() {
+		md.Size = -1
+	}
+
+	return md
+}
+
+// WithMetadata encrypts md along with the plaintext, and sets FlagMetadata in the header.
+// Older gfc cannot decrypt output with metadata correctly, so metadata is opt-in.
+func WithMetadata(md *Metadata) Option {
+	return func(o *options) {
+		o.metadata = md
+		if md != nil {
+			o.flags |= FlagMetadata
+		} else {
+			o.flags &^= FlagMetadata
+		}
+	}
+}
+
+// WithReadMetadata makes decryption fill md with metadata encrypted with WithMetadata.
+// md is left unchanged if the output has no metadata.
+func WithReadMetadata(md *Metadata) Option {
+	return func(o *options) {
+		o.metadataOut = md
+	}
+}
+
+func (md *Metadata) marshal() ([]byte, error) {
+	if md.Name != "" {
+		if err := validMetadataName(md.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	fields := []headerField{
+		{tag: tagMetadataMode, value: binary.BigEndian.AppendUint32(nil, uint32(md.Mode.Perm()))},
+	}
+
+	if md.Name != "" {
+		fields = append(fields, headerField{tag: tagMetadataName, value: []byte(md.Name)})
+	}
+
+	if !md.ModTime.IsZero() {
+		modTime := binary.BigEndian.AppendUint64(nil, uint64(md.ModTime.Unix()))
+		modTime = binary.BigEndian.AppendUint32(modTime, uint32(md.ModTime.Nanosecond()))
+
+		fields = append(fields, headerField{tag: tagMetadataModTime, value: modTime})
+	}
+
+	if md.Size >= 0 {
+		fields = append(fields, headerField{tag: tagMetadataSize, value: binary.BigEndian.AppendUint64(nil, uint64(md.Size))})
+	}
+
+	body := new(bytes.Buffer)
+	for _, field := range fields {
+		body.WriteByte(field.tag)
+		body.Write(binary.BigEndian.AppendUint16(nil, uint16(len(field.value))))
+		body.Write(field.value)
+	}
+
+	if body.Len() > math.MaxUint16 {
+		return nil, errors.Wrapf(ErrMetadata, "metadata too long (%d bytes)", body.Len())
+	}
+
+	return append(binary.BigEndian.AppendUint16(nil, uint16(body.Len())), body.Bytes()...), nil
+}
+
+func parseMetadata(b []byte) (*Metadata, error) {
+	md := &Metadata{Size: -1}
+	seen := make(map[uint8]bool)
+
+	for len(b) > 0 {
+		if len(b) < lenFieldFixed {
+			return nil, errors.Wrap(ErrMetadata, "truncated metadata field")
+		}
+
+		tag := b[0]
+		lenValue := int(binary.BigEndian.Uint16(b[1:lenFieldFixed]))
+		b = b[lenFieldFixed:]
+
+		if len(b) < lenValue {
+			return nil, errors.Wrapf(ErrMetadata, "truncated metadata field %d", tag)
+		}
+
+		if seen[tag] {
+			return nil, errors.Wrapf(ErrMetadata, "duplicate metadata field %d", tag)
+		}
+
+		seen[tag] = true
+		value := b[:lenValue]
+		b = b[lenValue:]
+
+		if err := md.unmarshalField(tag, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return md, nil
+}
+
+func (md *Metadata) unmarshalField(tag uint8, value []byte) error {
+	expected := map[uint8]int{tagMetadataMode: 4, tagMetadataModTime: 12, tagMetadataSize: 8}
+	if n, ok := expected[tag]; ok && len(value) != n {
+		return errors.Wrapf(ErrMetadata, "bad length %d for metadata field %d", len(value), tag)
+	}
+
+	switch tag {
+	case tagMetadataName:
+		if err := validMetadataName(string(value)); err != nil {
+			return err
+		}
+
+		md.Name = string(value)
+
+	case tagMetadataMode:
+		mode := binary.BigEndian.Uint32(value)
+		if mode&^uint32(fs.ModePerm) != 0 {
+			return errors.Wrapf(ErrMetadata, "bad mode %o", mode)
+		}
+
+		md.Mode = fs.FileMode(mode)
+
+	case tagMetadataModTime:
+		md.ModTime = time.Unix(int64(binary.BigEndian.Uint64(value)), int64(binary.BigEndian.Uint32(value[8:])))
+
+	case tagMetadataSize:
+		size := binary.BigEndian.Uint64(value)
+		if size > math.MaxInt64 {
+			return errors.Wrapf(ErrMetadata, "bad size %d", size)
+		}
+
+		md.Size = int64(size)
+
+	default:
+		return errors.Wrapf(ErrMetadata, "unknown metadata field %d", tag)
+	}
+
+	return nil
+}
+
+// validMetadataName rejects names which are not plain file names, since
+// decryption may use the name from (untrusted) ciphertext as output filename
+func validMetadataName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") || filepath.Base(name) != name {
+		return errors.Wrapf(ErrMetadata, "bad file name %q", name)
+	}
+
+	return nil
+}
+
+func writeMetadata(w io.Writer, md *Metadata) error {
+	b, err := md.marshal()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(b); err != nil {
+		return errors.Wrap(err, "failed to write metadata")
+	}
+
+	return nil
+}
+
+// readMetadata reads metadata at the start of plaintext from r
+func readMetadata(r io.Reader) (*Metadata, error) {
+	b := make([]byte, lenMetadataFixed)
+	if err := readFullMetadata(r, b); err != nil {
+		return nil, err
+	}
+
+	b = make([]byte, binary.BigEndian.Uint16(b))
+	if err := readFullMetadata(r, b); err != nil {
+		return nil, err
+	}
+
+	return parseMetadata(b)
+}
+
+// readFullMetadata is like io.ReadFull, but short plaintext is reported as ErrMetadata,
+// while decryption errors are returned as they are
+func readFullMetadata(r io.Reader, b []byte) error {
+	_, err := io.ReadFull(r, b)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return errors.Wrap(ErrMetadata, "truncated metadata")
+	}
+
+	return err
+}
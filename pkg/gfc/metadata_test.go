@@ -0,0 +1,93 @@
+package gfc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestMetadata(t *testing.T) {
+	key := make([]byte, aes256BitKeyFileLen)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("error filling random key bytes: %s", err.Error())
+	}
+
+	plaintext := bytes.Repeat([]byte("this is my plaintext"), 100)
+	md := &Metadata{
+		Name:    "backup.tar",
+		Mode:    0o640,
+		ModTime: time.Unix(1700000000, 123456789),
+		Size:    int64(len(plaintext)),
+	}
+
+	for _, chunkSize := range []uint32{0, 64} {
+		ciphertext := new(bytes.Buffer)
+
+		w, err := NewEncryptWriter(ciphertext, ModeAesGCM, key, WithMetadata(md), WithChunkSize(chunkSize))
+		if err != nil {
+			t.Fatalf("error creating encrypt writer: %s", err.Error())
+		}
+
+		if _, err := w.Write(plaintext); err != nil {
+			t.Fatalf("error writing plaintext: %s", err.Error())
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatalf("error closing encrypt writer: %s", err.Error())
+		}
+
+		hdr, err := ParseHeader(ciphertext.Bytes())
+		if err != nil || hdr.Flags&FlagMetadata == 0 {
+			t.Fatalf("expecting FlagMetadata in header, got %+v (%v)", hdr, err)
+		}
+
+		if bytes.Contains(ciphertext.Bytes(), []byte(md.Name)) {
+			t.Fatal("metadata is not encrypted")
+		}
+
+		var decryptedMD Metadata
+		r, err := NewDecryptReader(bytes.NewReader(ciphertext.Bytes()), key, WithReadMetadata(&decryptedMD))
+		if err != nil {
+			t.Fatalf("error creating decrypt reader: %s", err.Error())
+		}
+
+		if decryptedMD.Name != md.Name || decryptedMD.Mode != md.Mode || !decryptedMD.ModTime.Equal(md.ModTime) || decryptedMD.Size != md.Size {
+			t.Fatalf("unexpected metadata %+v", decryptedMD)
+		}
+
+		decrypted, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("error decrypting: %s", err.Error())
+		}
+
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatal("output does not match")
+		}
+
+		// Metadata is stripped even if not read
+		decrypted2, err := DecryptGCM(bytes.NewBuffer(ciphertext.Bytes()), key)
+		if err != nil || !bytes.Equal(decrypted2.Bytes(), plaintext) {
+			t.Fatalf("output without reading metadata does not match (%v)", err)
+		}
+	}
+
+	// Output without metadata leaves md unchanged
+	ciphertext, err := EncryptGCM(bytes.NewBuffer(plaintext), key)
+	if err != nil {
+		t.Fatalf("error encrypting: %s", err.Error())
+	}
+
+	unchanged := Metadata{Size: -1}
+	if _, err := DecryptGCM(ciphertext, key, WithReadMetadata(&unchanged)); err != nil || unchanged != (Metadata{Size: -1}) {
+		t.Fatalf("unexpected metadata %+v (%v)", unchanged, err)
+	}
+
+	for _, name := range []string{".", "..", "../etc/passwd", "dir/file", "a\x00b"} {
+		if _, err := EncryptGCM(bytes.NewBuffer(plaintext), key, WithMetadata(&Metadata{Name: name})); !errors.Is(err, ErrMetadata) {
+			t.Fatalf("name %q: expecting ErrMetadata, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package gfc
+
+// options represents optional parameters for gfc encryption
+type options struct {
+	flags       Flag
+	encoding    Encoding
+	compression Compression
+	chunkSize   uint32
+	mode        AlgoMode
+	kdf         KDFParams
+	payload     AlgoMode
+	legacyCTR   bool
+	passphrase  []byte
+	aad         []byte
+
+	metadata    *Metadata // Metadata to encrypt with the plaintext
+	metadataOut *Metadata // Metadata read from decrypted plaintext
+
+	recipients []Recipient // Recipients of multi-recipient encryption
+	identities []Identity  // Identities for decrypting hybrid output
+	aead       *aeadSpec   // Overrides AEAD cipher for mode
+	keys       *KeyCache   // Passphrase and keys derived from it
+}
+
+// Option configures optional parameters for gfc encryption
+type Option func(*options)
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		encoding:    EncodingNone,
+		compression: CompressionNone,
+		chunkSize:   DefaultChunkSize,
+		kdf:         defaultKDFParams(),
+		payload:     ModeInvalid, // Default depends on hybrid mode, see hybridPayload
+		keys:        NewKeyCache(),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithCompressed records in the header whether the plaintext was compressed with zstd before encryption
+func WithCompressed(compressed bool) Option {
+	if compressed {
+		return WithCompression(CompressionZstd)
+	}
+
+	return WithCompression(CompressionNone)
+}
+
+// WithCompression records in the header the compression applied to the plaintext before encryption,
+// so that decryption knows how to decompress it (see Header.Compression)
+func WithCompression(compression Compression) Option {
+	return func(o *options) {
+		o.compression = compression
+	}
+}
+
+// WithEncoding records in the header the encoding applied to the ciphertext after encryption
+func WithEncoding(encoding Encoding) Option {
+	return func(o *options) {
+		o.encoding = encoding
+	}
+}
+
+// WithChunkSize sets plaintext chunk size for chunked stream encryption of AEAD modes.
+// Chunk size 0 seals the whole plaintext in one shot.
+func WithChunkSize(chunkSize uint32) Option {
+	return func(o *options) {
+		o.chunkSize = chunkSize
+	}
+}
+
+// WithKDF sets the KDF and its parameters used to derive key from passphrase
+// for new symmetric key encryption. It has no effect when a keyfile is used.
+// Decryption always uses the KDF recorded in the header.
+func WithKDF(params KDFParams) Option {
+	return func(o *options) {
+		o.kdf = params
+	}
+}
+
+// WithPassphrase sets passphrase for symmetric key encryption and decryption when key is nil,
+// instead of prompting the user for it. Unlike key, passphrase is always used with a KDF.
+func WithPassphrase(passphrase []byte) Option {
+	return func(o *options) {
+		o.passphrase = passphrase
+	}
+}
+
+// WithAAD binds ciphertext to additional authenticated data (AAD), e.g. a database row ID or filename,
+// so that it cannot be swapped between records. AAD is not stored in the output, and decryption
+// fails unless the same AAD is given again. Empty AAD is the same as no AAD.
+func WithAAD(aad []byte) Option {
+	return func(o *options) {
+		o.aad = aad
+	}
+}
+
+// WithPBKDF2 derives key from passphrase with PBKDF2 using iterations and hash.
+// Like WithKDF, the parameters are recorded in the header for decryption.
+func WithPBKDF2(iterations uint32, hash KDFHash) Option {
+	return WithKDF(KDFParams{
+		KDF:        KDFPBKDF2,
+		Iterations: iterations,
+		Hash:       hash,
+	})
+}
+
+// WithPayload sets the AEAD mode used to encrypt the payload of hybrid modes,
+// i.e. AES256-GCM, XChaCha20-Poly1305, or ChaCha20-Poly1305.
+// The default is XChaCha20-Poly1305 for X25519, and AES256-GCM for other modes.
+func WithPayload(mode AlgoMode) Option {
+	return func(o *options) {
+		o.payload = mode
+	}
+}
+
+// WithRecipients adds recipients of multi-recipient encryption (ModeMultiRecipient)
+func WithRecipients(recipients ...Recipient) Option {
+	return func(o *options) {
+		o.recipients = append(o.recipients, recipients...)
+	}
+}
+
+// WithIdentities adds identities used to decrypt hybrid output, i.e. output of
+// RSA256-OEAP-Hybrid, X25519-Hybrid, and Multi-Recipient. If given, the key
+// passed to NewDecryptReader is ignored for hybrid output.
+func WithIdentities(identities ...Identity) Option {
+	return func(o *options) {
+		o.identities = append(o.identities, identities...)
+	}
+}
+
+// WithArchive records in the header whether the plaintext is a directory archive from NewArchiveReader
+func WithArchive(archive bool) Option {
+	return func(o *options) {
+		if archive {
+			o.flags |= FlagArchive
+		} else {
+			o.flags &^= FlagArchive
+		}
+	}
+}
+
+// WithLegacyCTR allows decrypting unauthenticated AES256-CTR output written by older gfc.
+// Such output is not authenticated, so tampered ciphertext decrypts to corrupted plaintext.
+func WithLegacyCTR(allow bool) Option {
+	return func(o *options) {
+		o.legacyCTR = allow
+	}
+}
+
+// WithMode sets the mode used to decrypt output without header,
+// i.e. output written by gfc before header was introduced.
+func WithMode(mode AlgoMode) Option {
+	return func(o *options) {
+		o.mode = mode
+	}
+}
+
+func withAEAD(spec aeadSpec) Option {
+	return func(o *options) {
+		o.aead = &spec
+	}
+}
@@ -6,11 +6,15 @@ package gfc
 import (
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
 	"golang.org/x/term"
 )
 
@@ -20,60 +24,138 @@ const (
 	aes256BitKeyFileLen int = 32
 )
 
-func getPass() []byte {
-	os.Stdout.Write([]byte("Passphrase (will not echo)\n"))
-	passphrase, err := term.ReadPassword(0)
+// getPass prompts for passphrase on the controlling terminal (/dev/tty), so that
+// stdin and stdout stay free for gfc input and output. If there is no /dev/tty,
+// stdin is used if it is a terminal, with the prompt written to stderr.
+// If confirm is true (new encryption), the passphrase is asked twice.
+func getPass(confirm bool) ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
 	if err != nil {
-		panic("failed to read password from stdin: " + err.Error())
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return nil, errors.Wrap(ErrPassphrase, "no terminal for passphrase prompt")
+		}
+
+		return promptPass(os.Stdin, os.Stderr, confirm)
 	}
 
-	return passphrase
+	defer tty.Close()
+
+	return promptPass(tty, tty, confirm)
 }
 
-func generateSaltPBKDF2(salt []byte) []byte {
-	if salt != nil {
-		if lenSalt := len(salt); lenSalt != lenPBKDF2Salt {
-			panic(fmt.Sprintf("bad salt len - expecting %d, got %d", lenPBKDF2Salt, lenSalt))
-		}
+func promptPass(in *os.File, out io.Writer, confirm bool) ([]byte, error) {
+	passphrase, err := readPass(in, out, "Passphrase (will not echo): ")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(passphrase) == 0 {
+		return nil, errors.Wrap(ErrPassphrase, "empty passphrase")
+	}
 
-		return salt
+	if !confirm {
+		return passphrase, nil
 	}
 
-	salt = make([]byte, lenPBKDF2Salt)
-	n, err := rand.Read(salt)
+	confirmation, err := readPass(in, out, "Confirm passphrase: ")
 	if err != nil {
-		panic("failed to read random salt: " + err.Error())
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare(passphrase, confirmation) != 1 {
+		return nil, ErrPassphraseMismatch
 	}
 
-	if n != lenPBKDF2Salt {
-		panic(fmt.Sprintf("unexpected random salt len - expecting %d, got %d", lenPBKDF2Salt, n))
+	return passphrase, nil
+}
+
+func readPass(in *os.File, out io.Writer, prompt string) ([]byte, error) {
+	fmt.Fprint(out, prompt)
+	passphrase, err := term.ReadPassword(int(in.Fd()))
+	fmt.Fprintln(out)
+
+	if err != nil {
+		return nil, errors.Wrap(ErrPassphrase, err.Error())
 	}
 
-	return salt
+	return passphrase, nil
 }
 
-/* Derive 256-bit key and salt using PBKDF2 */
-func generateKeySaltPBKDF2(passphrase, salt []byte) ([]byte, []byte) {
-	salt = generateSaltPBKDF2(salt)
+// newSalt returns a new random salt for key derivation
+func newSalt() ([]byte, error) {
+	salt := make([]byte, lenPBKDF2Salt)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrapf(ErrRandom, "failed to read random salt: %s", err)
+	}
 
-	return pbkdf2.Key(passphrase, salt, pbkdf2Rounds, lenPBKDF2Salt, sha256.New), salt
+	return salt, nil
 }
 
-// If symmetric key is nil, getPass() is called to get passphrase from user.
-// If salt is nil, new salt is created.
-func keySaltPBKDF2(symmetricKey, salt []byte) ([]byte, []byte, error) {
-	if symmetricKey != nil {
-		keyLen := len(symmetricKey)
-		if keyLen != aes256BitKeyFileLen {
-			return nil, nil, errors.Wrapf(ErrInvalidaes256BitKeyFileLen, "keyfile length is %d", keyLen)
-		}
-		// If salt is new (encryption), generate new salt
-		salt = generateSaltPBKDF2(salt)
-		return symmetricKey, salt, nil
+// keyPBKDF2 derives 256-bit key from passphrase and salt using PBKDF2 with params.
+// Parameters are checked first, since they may come from an untrusted header.
+func keyPBKDF2(passphrase, salt []byte, params KDFParams) ([]byte, error) {
+	if err := params.validatePBKDF2(); err != nil {
+		return nil, err
+	}
+
+	h := sha256.New
+	if params.Hash == KDFHashSHA512 {
+		h = sha512.New
 	}
 
-	// Passphrase
-	symmetricKey, salt = generateKeySaltPBKDF2(getPass(), salt)
+	return pbkdf2.Key(passphrase, salt, int(params.Iterations), aes256BitKeyFileLen, h), nil
+}
+
+func (p KDFParams) validatePBKDF2() error {
+	switch {
+	case p.Iterations == 0:
+		return errors.Wrap(ErrKDFParams, "zero PBKDF2 iterations")
+
+	case p.Iterations > maxPBKDF2Iterations:
+		return errors.Wrapf(ErrKDFParams, "PBKDF2 iterations %d exceeds limit %d", p.Iterations, maxPBKDF2Iterations)
+	}
+
+	switch p.Hash {
+	case KDFHashSHA256, KDFHashSHA512:
+		return nil
+	}
+
+	return errors.Wrapf(ErrKDFParams, "unknown PBKDF2 hash %d", p.Hash)
+}
+
+// keyScrypt derives 256-bit key from passphrase and salt using scrypt with params.
+// Parameters are checked first, since they may come from an untrusted header.
+func keyScrypt(passphrase, salt []byte, params KDFParams) ([]byte, error) {
+	if err := params.validateScrypt(); err != nil {
+		return nil, err
+	}
+
+	key, err := scrypt.Key(passphrase, salt, int(params.N), int(params.R), int(params.P), aes256BitKeyFileLen)
+	if err != nil {
+		return nil, errors.Wrap(err, "scrypt failed")
+	}
+
+	return key, nil
+}
+
+func (p KDFParams) validateScrypt() error {
+	switch {
+	case p.N <= 1 || p.N&(p.N-1) != 0:
+		return errors.Wrapf(ErrKDFParams, "scrypt N %d is not a power of 2 greater than 1", p.N)
+
+	case p.R == 0 || p.P == 0:
+		return errors.Wrapf(ErrKDFParams, "zero scrypt r or p (r=%d, p=%d)", p.R, p.P)
+
+	case uint64(p.R)*uint64(p.P) >= 1<<30:
+		return errors.Wrapf(ErrKDFParams, "scrypt r*p too large (r=%d, p=%d)", p.R, p.P)
+
+	// scrypt allocates 128*r*N bytes for its working memory, and 128*r*p bytes for its PBKDF2 output
+	case 128*uint64(p.R)*(uint64(p.N)+uint64(p.P)) > maxScryptMemory:
+		return errors.Wrapf(ErrKDFParams, "scrypt memory exceeds limit (N=%d, r=%d, p=%d)", p.N, p.R, p.P)
+
+	case uint64(p.N)*uint64(p.R)*uint64(p.P) > maxScryptCost:
+		return errors.Wrapf(ErrKDFParams, "scrypt cost N*r*p exceeds limit %d (N=%d, r=%d, p=%d)", maxScryptCost, p.N, p.R, p.P)
+	}
 
-	return symmetricKey, salt, nil
+	return nil
 }
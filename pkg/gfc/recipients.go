@@ -0,0 +1,274 @@
+package gfc
+
+// This file provides multi-recipient encryption for gfc (see hybrid.go).
+// One payload is encrypted with a random file key, and the header carries
+// a stanza for each recipient, which may be any of RSA public key,
+// X25519 public key, passphrase, or 256-bit keyfile. Any single recipient
+// can decrypt the output. Passphrase and keyfile stanza bodies are:
+//
+//	Passphrase: <Salt (32 bytes)> <KDF parameters> <Wrapped file key (48 bytes)>
+//	Keyfile:    <Salt (32 bytes)> <Wrapped file key (48 bytes)>
+//
+// The file key is wrapped with ChaCha20-Poly1305, using a key derived from
+// the passphrase with the recorded KDF, or from the keyfile with HKDF-SHA256.
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/pem"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/hkdf"
+)
+
+const infoKeyfileWrapKeyHKDF = "gfc keyfile file key"
+
+// Passphrase recipients and identities may be shared by concurrent
+// encryptions (e.g. in batch), so mu guards prompting for passphrase.
+type passphraseRecipient struct {
+	mu         sync.Mutex
+	passphrase []byte
+	kdf        KDFParams
+}
+
+type passphraseIdentity struct {
+	mu         sync.Mutex
+	passphrase []byte
+}
+
+type keyfileRecipient struct {
+	key []byte
+}
+
+type keyfileIdentity struct {
+	key []byte
+}
+
+// RecipientPassphrase returns recipient for passphrase, whose key is derived with kdf.
+// If passphrase is nil, the user is prompted for passphrase during encryption.
+func RecipientPassphrase(passphrase []byte, kdf KDFParams) Recipient {
+	return &passphraseRecipient{passphrase: passphrase, kdf: kdf}
+}
+
+// IdentityPassphrase returns identity for passphrase.
+// If passphrase is nil, the user is prompted for passphrase when a passphrase stanza is found.
+func IdentityPassphrase(passphrase []byte) Identity {
+	return &passphraseIdentity{passphrase: passphrase}
+}
+
+// RecipientKeyfile returns recipient for 256-bit keyfile
+func RecipientKeyfile(key []byte) (Recipient, error) {
+	if keyLen := len(key); keyLen != aes256BitKeyFileLen {
+		return nil, errors.Wrapf(ErrInvalidaes256BitKeyFileLen, "keyfile length is %d", keyLen)
+	}
+
+	return keyfileRecipient{key: key}, nil
+}
+
+// IdentityKeyfile returns identity for 256-bit keyfile
+func IdentityKeyfile(key []byte) (Identity, error) {
+	if keyLen := len(key); keyLen != aes256BitKeyFileLen {
+		return nil, errors.Wrapf(ErrInvalidaes256BitKeyFileLen, "keyfile length is %d", keyLen)
+	}
+
+	return keyfileIdentity{key: key}, nil
+}
+
+// ParseRecipients detects key type of key, and returns its recipients:
+// PEM-encoded RSA public key, list of text-encoded X25519 public keys, or 256-bit keyfile (see DecodeKeyfile).
+func ParseRecipients(key []byte) ([]Recipient, error) {
+	switch {
+	case isPEM(key):
+		r, err := RecipientRSA(key)
+		if err != nil {
+			return nil, err
+		}
+
+		return []Recipient{r}, nil
+
+	case bytes.Contains(bytes.ToUpper(key), []byte(PrefixX25519PublicKey)):
+		return RecipientsX25519(key)
+
+	case len(DecodeKeyfile(key)) == aes256BitKeyFileLen:
+		r, err := RecipientKeyfile(DecodeKeyfile(key))
+		if err != nil {
+			return nil, err
+		}
+
+		return []Recipient{r}, nil
+	}
+
+	return nil, errors.Wrap(ErrKeySource, "unknown recipient key type")
+}
+
+// ParseIdentities detects key type of key, and returns its identities:
+// PEM-encoded RSA private key, list of text-encoded X25519 private keys, or 256-bit keyfile (see DecodeKeyfile).
+// If key is nil, a passphrase identity is returned.
+func ParseIdentities(key []byte) ([]Identity, error) {
+	switch {
+	case key == nil:
+		return []Identity{IdentityPassphrase(nil)}, nil
+
+	case isPEM(key):
+		id, err := IdentityRSA(key)
+		if err != nil {
+			return nil, err
+		}
+
+		return []Identity{id}, nil
+
+	case bytes.Contains(bytes.ToUpper(key), []byte(PrefixX25519PrivateKey)):
+		return IdentitiesX25519(key)
+
+	case len(DecodeKeyfile(key)) == aes256BitKeyFileLen:
+		id, err := IdentityKeyfile(DecodeKeyfile(key))
+		if err != nil {
+			return nil, err
+		}
+
+		return []Identity{id}, nil
+	}
+
+	return nil, errors.Wrap(ErrKeySource, "unknown identity key type")
+}
+
+func isPEM(key []byte) bool {
+	block, _ := pem.Decode(key)
+	return block != nil
+}
+
+func (r *passphraseRecipient) wrap(fileKey []byte) (Stanza, error) {
+	if r.kdf.KDF == KDFNone {
+		return Stanza{}, errors.Wrap(ErrKeySource, "missing passphrase KDF")
+	}
+
+	// Confirm new passphrase, so that a typo does not make the output unrecoverable
+	passphrase, err := promptOnce(&r.mu, &r.passphrase, true)
+	if err != nil {
+		return Stanza{}, err
+	}
+
+	salt, err := newSalt()
+	if err != nil {
+		return Stanza{}, err
+	}
+
+	wrapKey, err := r.kdf.deriveKey(passphrase, salt)
+	if err != nil {
+		return Stanza{}, errors.Wrap(err, ErrPBKDF2KeySalt.Error())
+	}
+
+	wrapped, err := sealFileKey(wrapKey, fileKey)
+	if err != nil {
+		return Stanza{}, err
+	}
+
+	body := append(salt, r.kdf.marshal()...)
+
+	return Stanza{Type: StanzaPassphrase, Body: append(body, wrapped...)}, nil
+}
+
+func (id *passphraseIdentity) unwrap(stanza Stanza) ([]byte, error) {
+	if stanza.Type != StanzaPassphrase || len(stanza.Body) <= lenPBKDF2Salt+lenWrappedFileKey {
+		return nil, ErrStanzaNoMatch
+	}
+
+	salt := stanza.Body[:lenPBKDF2Salt]
+	wrapped := stanza.Body[len(stanza.Body)-lenWrappedFileKey:]
+
+	kdf, err := unmarshalKDFParams(stanza.Body[lenPBKDF2Salt : len(stanza.Body)-lenWrappedFileKey])
+	if err != nil {
+		return nil, err
+	}
+
+	if kdf.KDF == KDFNone {
+		return nil, errors.Wrap(ErrUnmarshalHeader, "passphrase stanza without KDF")
+	}
+
+	// Only prompt once, even if there are multiple passphrase stanzas
+	passphrase, err := promptOnce(&id.mu, &id.passphrase, false)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapKey, err := kdf.deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrPBKDF2KeySalt.Error())
+	}
+
+	return openFileKey(wrapKey, wrapped)
+}
+
+// promptOnce returns *passphrase, prompting for it first if it is nil
+func promptOnce(mu *sync.Mutex, passphrase *[]byte, confirm bool) ([]byte, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if *passphrase == nil {
+		p, err := getPass(confirm)
+		if err != nil {
+			return nil, err
+		}
+
+		*passphrase = p
+	}
+
+	return *passphrase, nil
+}
+
+// wrapKeyKeyfile derives key for wrapping file key from keyfile
+func wrapKeyKeyfile(key, salt []byte) ([]byte, error) {
+	kdf := hkdf.New(sha256.New, key, salt, []byte(infoKeyfileWrapKeyHKDF))
+
+	wrapKey := make([]byte, lenFileKey)
+	if _, err := io.ReadFull(kdf, wrapKey); err != nil {
+		return nil, errors.Wrap(err, "HKDF failed")
+	}
+
+	return wrapKey, nil
+}
+
+func (r keyfileRecipient) wrap(fileKey []byte) (Stanza, error) {
+	salt := make([]byte, lenPBKDF2Salt)
+	if _, err := rand.Read(salt); err != nil {
+		return Stanza{}, errors.Wrapf(ErrRandom, "failed to read random salt: %s", err)
+	}
+
+	wrapKey, err := wrapKeyKeyfile(r.key, salt)
+	if err != nil {
+		return Stanza{}, err
+	}
+
+	wrapped, err := sealFileKey(wrapKey, fileKey)
+	if err != nil {
+		return Stanza{}, err
+	}
+
+	return Stanza{Type: StanzaKeyfile, Body: append(salt, wrapped...)}, nil
+}
+
+func (id keyfileIdentity) unwrap(stanza Stanza) ([]byte, error) {
+	if stanza.Type != StanzaKeyfile || len(stanza.Body) != lenPBKDF2Salt+lenWrappedFileKey {
+		return nil, ErrStanzaNoMatch
+	}
+
+	wrapKey, err := wrapKeyKeyfile(id.key, stanza.Body[:lenPBKDF2Salt])
+	if err != nil {
+		return nil, err
+	}
+
+	return openFileKey(wrapKey, stanza.Body[lenPBKDF2Salt:])
+}
+
+// EncryptMultiRecipient encrypts plaintext once, so that any of recipients can decrypt it
+func EncryptMultiRecipient(plaintext Buffer, recipients []Recipient, opts ...Option) (Buffer, error) {
+	return encryptBuffer(ModeMultiRecipient, plaintext, nil, append(opts[:len(opts):len(opts)], WithRecipients(recipients...)))
+}
+
+// DecryptMultiRecipient decrypts ciphertext with any of identities
+func DecryptMultiRecipient(ciphertext Buffer, identities []Identity, opts ...Option) (Buffer, error) {
+	return decryptBuffer(ModeMultiRecipient, ciphertext, nil, append(opts[:len(opts):len(opts)], WithIdentities(identities...)))
+}
@@ -0,0 +1,104 @@
+package gfc
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestMultiRecipient(t *testing.T) {
+	pubKey, err := os.ReadFile("../../assets/files/pub.pem")
+	if err != nil {
+		t.Fatalf("failed to read RSA public key: %s", err.Error())
+	}
+
+	priKey, err := os.ReadFile("../../assets/files/pri.pem")
+	if err != nil {
+		t.Fatalf("failed to read RSA private key: %s", err.Error())
+	}
+
+	keyfile, err := os.ReadFile("../../assets/files/aes.key")
+	if err != nil {
+		t.Fatalf("failed to read keyfile: %s", err.Error())
+	}
+
+	x25519Pub, x25519Pri, err := GenerateX25519()
+	if err != nil {
+		t.Fatalf("failed to generate X25519 keypair: %s", err.Error())
+	}
+
+	// Cheap KDF for tests
+	kdf := KDFParams{KDF: KDFPBKDF2, Iterations: 10, Hash: KDFHashSHA256}
+	passphrase := []byte("my passphrase")
+
+	var recipients []Recipient
+	for _, key := range [][]byte{pubKey, []byte(x25519Pub), keyfile} {
+		r, err := ParseRecipients(key)
+		if err != nil {
+			t.Fatalf("failed to parse recipient: %s", err.Error())
+		}
+
+		recipients = append(recipients, r...)
+	}
+
+	recipients = append(recipients, RecipientPassphrase(passphrase, kdf))
+
+	plaintext := bytes.Repeat([]byte("this is my plaintext"), 100)
+
+	ciphertext, err := EncryptMultiRecipient(bytes.NewBuffer(plaintext), recipients)
+	if err != nil {
+		t.Fatalf("error encrypting: %s", err.Error())
+	}
+
+	hdr, err := ParseHeader(ciphertext.Bytes())
+	if err != nil {
+		t.Fatalf("failed to parse header: %s", err.Error())
+	}
+
+	if hdr.Mode != ModeMultiRecipient || len(hdr.Stanzas) != len(recipients) {
+		t.Fatalf("unexpected header %+v", hdr)
+	}
+
+	var identities []Identity
+	for _, key := range [][]byte{priKey, []byte(x25519Pri), keyfile} {
+		id, err := ParseIdentities(key)
+		if err != nil {
+			t.Fatalf("failed to parse identity: %s", err.Error())
+		}
+
+		identities = append(identities, id...)
+	}
+
+	identities = append(identities, IdentityPassphrase(passphrase))
+
+	// Any recipient can decrypt
+	for _, id := range identities {
+		decrypted, err := DecryptMultiRecipient(bytes.NewBuffer(ciphertext.Bytes()), []Identity{id})
+		if err != nil {
+			t.Fatalf("error decrypting: %s", err.Error())
+		}
+
+		if !bytes.Equal(decrypted.Bytes(), plaintext) {
+			t.Fatal("output does not match")
+		}
+	}
+
+	otherKeyfile, _ := IdentityKeyfile(bytes.Repeat([]byte{1}, aes256BitKeyFileLen))
+	_, otherX25519, _ := GenerateX25519()
+	otherIdentities, _ := IdentitiesX25519([]byte(otherX25519))
+
+	wrong := []Identity{
+		otherKeyfile,
+		otherIdentities[0],
+		IdentityPassphrase([]byte("wrong passphrase")),
+	}
+
+	if _, err := DecryptMultiRecipient(bytes.NewBuffer(ciphertext.Bytes()), wrong); !errors.Is(err, ErrNoRecipient) {
+		t.Fatalf("expecting ErrNoRecipient, got %v", err)
+	}
+
+	if _, err := ParseRecipients([]byte("not a key")); err == nil {
+		t.Fatal("expecting error for unknown recipient")
+	}
+}
@@ -0,0 +1,180 @@
+package gfc
+
+// This file provides Ed25519 signatures for gfc.
+// The message is hashed with SHA-512, so detached signatures can be created
+// and verified with constant memory. The digest is prefixed with a context string
+// before signing, so gfc signatures cannot be confused with other Ed25519 signatures.
+// A gfc signature is:
+//
+//	<Magic (4 bytes)> <Version (1 byte)> <Signer public key (32 bytes)> <Ed25519 signature (64 bytes)>
+//
+// An attached signature is the signature followed by the message:
+//
+//	<Signature> <Message>
+//
+// Keys are text-encoded like X25519 keys (see alg_x25519.go), e.g.
+// GFC-ED25519-PUB-... for public keys and GFC-ED25519-SEC-... for private keys (seeds).
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	PrefixEd25519PublicKey  = "GFC-ED25519-PUB-"
+	PrefixEd25519PrivateKey = "GFC-ED25519-SEC-"
+
+	signatureVersion uint8 = 1
+	contextSignature       = "gfc Ed25519 signature v1\x00"
+
+	// LenSignature is the length of gfc signature
+	LenSignature = 4 + 1 + ed25519.PublicKeySize + ed25519.SignatureSize
+)
+
+var signatureMagic = []byte{0x89, 'G', 'F', 'S'}
+
+// GenerateEd25519 generates a new Ed25519 keypair, and returns the text-encoded public and private keys
+func GenerateEd25519() (string, string, error) {
+	pub, pri, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to generate Ed25519 key")
+	}
+
+	return encodeTextKey(PrefixEd25519PublicKey, pub), encodeTextKey(PrefixEd25519PrivateKey, pri.Seed()), nil
+}
+
+// PublicKeyEd25519 returns the text-encoded public key of text-encoded private key
+func PublicKeyEd25519(privateKey string) (string, error) {
+	pri, err := parsePriKeyEd25519([]byte(privateKey))
+	if err != nil {
+		return "", err
+	}
+
+	pub, _ := pri.Public().(ed25519.PublicKey)
+
+	return encodeTextKey(PrefixEd25519PublicKey, pub), nil
+}
+
+// parsePriKeyEd25519 parses the first text-encoded private key in privateKey
+func parsePriKeyEd25519(privateKey []byte) (ed25519.PrivateKey, error) {
+	seeds, err := parseTextKeys(PrefixEd25519PrivateKey, privateKey, ErrParseEd25519)
+	if err != nil {
+		return nil, err
+	}
+
+	return ed25519.NewKeyFromSeed(seeds[0]), nil
+}
+
+// signatureDigest returns the signed digest of message
+func signatureDigest(message io.Reader) ([]byte, error) {
+	h := sha512.New()
+	if _, err := io.Copy(h, message); err != nil {
+		return nil, errors.Wrap(err, "failed to read message")
+	}
+
+	return h.Sum([]byte(contextSignature)), nil
+}
+
+// SignEd25519 returns detached gfc signature of message, signed with text-encoded private key
+func SignEd25519(message io.Reader, privateKey []byte) ([]byte, error) {
+	pri, err := parsePriKeyEd25519(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := signatureDigest(message)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, _ := pri.Public().(ed25519.PublicKey)
+
+	signature := append(append([]byte{}, signatureMagic...), signatureVersion)
+	signature = append(signature, pub...)
+
+	return append(signature, ed25519.Sign(pri, digest)...), nil
+}
+
+// VerifyEd25519 verifies detached gfc signature of message against publicKeys,
+// a list of text-encoded Ed25519 public keys (one per line).
+// It returns the text-encoded public key of the signer.
+func VerifyEd25519(message io.Reader, signature []byte, publicKeys []byte) (string, error) {
+	if len(signature) != LenSignature {
+		return "", errors.Wrapf(ErrParseSignature, "bad signature length %d", len(signature))
+	}
+
+	if !bytes.Equal(signature[:len(signatureMagic)], signatureMagic) {
+		return "", errors.Wrap(ErrParseSignature, "missing gfc signature magic")
+	}
+
+	if version := signature[len(signatureMagic)]; version != signatureVersion {
+		return "", errors.Wrapf(ErrParseSignature, "unsupported signature version %d", version)
+	}
+
+	pubs, err := parseTextKeys(PrefixEd25519PublicKey, publicKeys, ErrParseEd25519)
+	if err != nil {
+		return "", err
+	}
+
+	offset := len(signatureMagic) + 1
+	signer := ed25519.PublicKey(signature[offset : offset+ed25519.PublicKeySize])
+	sig := signature[offset+ed25519.PublicKeySize:]
+
+	trusted := false
+	for _, pub := range pubs {
+		if signer.Equal(ed25519.PublicKey(pub)) {
+			trusted = true
+			break
+		}
+	}
+
+	if !trusted {
+		return "", errors.Wrapf(ErrBadSignature, "signer %s is not in public keys", encodeTextKey(PrefixEd25519PublicKey, signer))
+	}
+
+	digest, err := signatureDigest(message)
+	if err != nil {
+		return "", err
+	}
+
+	if !ed25519.Verify(signer, digest, sig) {
+		return "", errors.Wrap(ErrBadSignature, "message was modified or signature is invalid")
+	}
+
+	return encodeTextKey(PrefixEd25519PublicKey, signer), nil
+}
+
+// SignAttachedEd25519 returns message with attached gfc signature
+func SignAttachedEd25519(message Buffer, privateKey []byte) (Buffer, error) {
+	msg := message.Bytes()
+
+	signature, err := SignEd25519(bytes.NewReader(msg), privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewBuffer(append(signature, msg...)), nil
+}
+
+// VerifyAttachedEd25519 verifies message with attached gfc signature against publicKeys,
+// and returns the message and text-encoded public key of the signer.
+func VerifyAttachedEd25519(signed Buffer, publicKeys []byte) (Buffer, string, error) {
+	b := signed.Bytes()
+	if len(b) < LenSignature {
+		return nil, "", errors.Wrap(ErrParseSignature, "signed message too short")
+	}
+
+	msg := b[LenSignature:]
+
+	signer, err := VerifyEd25519(bytes.NewReader(msg), b[:LenSignature], publicKeys)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return bytes.NewBuffer(msg), signer, nil
+}
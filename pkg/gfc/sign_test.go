@@ -0,0 +1,77 @@
+package gfc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSignEd25519(t *testing.T) {
+	pub, pri, err := GenerateEd25519()
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 keypair: %s", err.Error())
+	}
+
+	derived, err := PublicKeyEd25519(pri)
+	if err != nil || derived != pub {
+		t.Fatalf("unexpected public key - expecting %s, got %s (%v)", pub, derived, err)
+	}
+
+	otherPub, _, _ := GenerateEd25519()
+	publicKeys := []byte("# comment\n" + otherPub + "\n" + pub + "\n")
+	message := bytes.Repeat([]byte("this is my message"), 100)
+
+	signature, err := SignEd25519(bytes.NewReader(message), []byte(pri))
+	if err != nil {
+		t.Fatalf("error signing: %s", err.Error())
+	}
+
+	if len(signature) != LenSignature {
+		t.Fatalf("unexpected signature length %d", len(signature))
+	}
+
+	signer, err := VerifyEd25519(bytes.NewReader(message), signature, publicKeys)
+	if err != nil {
+		t.Fatalf("error verifying: %s", err.Error())
+	}
+
+	if signer != pub {
+		t.Fatalf("unexpected signer - expecting %s, got %s", pub, signer)
+	}
+
+	modified := append([]byte{}, message...)
+	modified[0] ^= 1
+
+	if _, err := VerifyEd25519(bytes.NewReader(modified), signature, publicKeys); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("modified message: expecting ErrBadSignature, got %v", err)
+	}
+
+	if _, err := VerifyEd25519(bytes.NewReader(message), signature, []byte(otherPub)); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("untrusted signer: expecting ErrBadSignature, got %v", err)
+	}
+
+	if _, err := VerifyEd25519(bytes.NewReader(message), signature[1:], publicKeys); !errors.Is(err, ErrParseSignature) {
+		t.Fatalf("short signature: expecting ErrParseSignature, got %v", err)
+	}
+
+	signed, err := SignAttachedEd25519(bytes.NewBuffer(message), []byte(pri))
+	if err != nil {
+		t.Fatalf("error signing attached: %s", err.Error())
+	}
+
+	verified, _, err := VerifyAttachedEd25519(bytes.NewBuffer(signed.Bytes()), publicKeys)
+	if err != nil {
+		t.Fatalf("error verifying attached: %s", err.Error())
+	}
+
+	if !bytes.Equal(verified.Bytes(), message) {
+		t.Fatal("output does not match")
+	}
+
+	tampered := append([]byte{}, signed.Bytes()...)
+	tampered[len(tampered)-1] ^= 1
+
+	if _, _, err := VerifyAttachedEd25519(bytes.NewBuffer(tampered), publicKeys); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("tampered attached: expecting ErrBadSignature, got %v", err)
+	}
+}
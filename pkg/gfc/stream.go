@@ -0,0 +1,251 @@
+package gfc
+
+// This file provides chunked stream encryption for gfc AEAD ciphers
+// (AES256-GCM and (X)ChaCha20-Poly1305), so that inputs larger than
+// memory can be encrypted with constant memory usage (see NewEncryptWriter).
+//
+// The construction follows STREAM (Hoang, Reyhanitabar, Rogaway, and Vizár).
+// The plaintext is split into chunks of header ChunkSize bytes,
+// and each chunk is sealed separately with nonce:
+//
+//	<Nonce prefix (from header)> <Chunk counter (4 bytes)> <Last chunk flag (1 byte)>
+//
+// The chunk counter detects reordered chunks, the last chunk flag detects
+// truncation at chunk boundaries, and the random nonce prefix together with
+// the serialized header as additional data detects chunks swapped between files.
+// The nonce prefix is only 7 bytes for 12-byte nonces, which is safe because symmetric
+// key output is encrypted with a per-file subkey (see fileSubkey), and hybrid output
+// with a random file key, so prefixes never have to be unique across files.
+// The output layout is:
+//
+//	<Header> <Chunk 0> <Chunk 1> ... <Last chunk>
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultChunkSize     uint32 = 64 << 10
+	maxChunkSize         uint32 = 16 << 20
+	lenStreamNonceSuffix int    = 4 + 1 // Chunk counter and last chunk flag
+)
+
+// isStreamMode reports whether mode supports chunked stream encryption
+func isStreamMode(mode AlgoMode) bool {
+	switch mode {
+	case ModeAesGCM, ModeXChaCha20Poly1305, ModeChaCha20Poly1305:
+		return true
+	}
+
+	return false
+}
+
+func newStreamWriter(w io.Writer, aead cipher.AEAD, hdr *Header, aad []byte) *streamWriter {
+	chunkSize := int(hdr.ChunkSize)
+
+	return &streamWriter{
+		w:         w,
+		aead:      aead,
+		aad:       aad,
+		nonce:     newStreamNonce(hdr.Nonce, len(hdr.Nonce)+lenStreamNonceSuffix),
+		chunkSize: chunkSize,
+		buf:       make([]byte, 0, chunkSize+aead.Overhead()),
+	}
+}
+
+// streamNonce builds per-chunk nonces from nonce prefix
+type streamNonce struct {
+	nonce     []byte
+	counter   uint32
+	exhausted bool
+}
+
+func newStreamNonce(prefix []byte, nonceSize int) *streamNonce {
+	nonce := make([]byte, nonceSize)
+	copy(nonce, prefix)
+
+	return &streamNonce{nonce: nonce}
+}
+
+// next returns nonce for the next chunk
+func (n *streamNonce) next(last bool) ([]byte, error) {
+	if n.exhausted {
+		return nil, ErrStreamTooLong
+	}
+
+	lenPrefix := len(n.nonce) - lenStreamNonceSuffix
+	binary.BigEndian.PutUint32(n.nonce[lenPrefix:], n.counter)
+
+	n.nonce[len(n.nonce)-1] = 0
+	if last {
+		n.nonce[len(n.nonce)-1] = 1
+	}
+
+	if n.counter == math.MaxUint32 {
+		n.exhausted = true
+	} else {
+		n.counter++
+	}
+
+	return n.nonce, nil
+}
+
+type streamWriter struct {
+	w         io.Writer
+	aead      cipher.AEAD
+	aad       []byte
+	nonce     *streamNonce
+	chunkSize int
+	buf       []byte // Buffered plaintext of the current chunk
+	closed    bool
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, ErrStreamClosed
+	}
+
+	var written int
+	for len(p) > 0 {
+		// A full chunk is only sealed once more data arrives,
+		// so that the last chunk is always sealed by Close.
+		if len(s.buf) == s.chunkSize {
+			if err := s.seal(false); err != nil {
+				return written, err
+			}
+		}
+
+		n := s.chunkSize - len(s.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+
+		s.buf = append(s.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+// Close seals and writes the last chunk
+func (s *streamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+
+	return s.seal(true)
+}
+
+func (s *streamWriter) seal(last bool) error {
+	nonce, err := s.nonce.next(last)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.w.Write(s.aead.Seal(s.buf[:0], nonce, s.buf, s.aad)); err != nil {
+		return errors.Wrap(err, "failed to write chunk")
+	}
+
+	s.buf = s.buf[:0]
+
+	return nil
+}
+
+type streamReader struct {
+	r         *bufio.Reader
+	aead      cipher.AEAD
+	aad       []byte
+	nonce     *streamNonce
+	chunk     []byte // Ciphertext buffer
+	plaintext []byte // Decrypted bytes not yet read
+	done      bool   // Last chunk was opened
+	err       error
+}
+
+func newStreamReader(r io.Reader, aead cipher.AEAD, hdr *Header, aad []byte) *streamReader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	return &streamReader{
+		r:     br,
+		aead:  aead,
+		aad:   aad,
+		nonce: newStreamNonce(hdr.Nonce, len(hdr.Nonce)+lenStreamNonceSuffix),
+		chunk: make([]byte, int(hdr.ChunkSize)+aead.Overhead()),
+	}
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.plaintext) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+
+		s.err = s.open()
+	}
+
+	n := copy(p, s.plaintext)
+	s.plaintext = s.plaintext[n:]
+
+	return n, nil
+}
+
+// open reads and decrypts the next chunk. It returns io.EOF after the last chunk.
+func (s *streamReader) open() error {
+	if s.done {
+		return io.EOF
+	}
+
+	n, err := io.ReadFull(s.r, s.chunk)
+
+	var last bool
+	switch {
+	case errors.Is(err, io.EOF):
+		return errors.Wrap(ErrStreamTruncated, "missing last chunk")
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		last = true
+
+	case err != nil:
+		return errors.Wrap(err, "failed to read chunk")
+
+	default:
+		// A full chunk is the last chunk only if nothing follows
+		_, err := s.r.Peek(1)
+		if errors.Is(err, io.EOF) {
+			last = true
+		} else if err != nil {
+			return errors.Wrap(err, "failed to read chunk")
+		}
+	}
+
+	if n < s.aead.Overhead() {
+		return errors.Wrapf(ErrStreamTruncated, "chunk too short (%d bytes)", n)
+	}
+
+	nonce, err := s.nonce.next(last)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := s.aead.Open(s.chunk[:0], nonce, s.chunk[:n], s.aad)
+	if err != nil {
+		return errors.Wrap(ErrOpenStream, err.Error())
+	}
+
+	s.plaintext = plaintext
+	s.done = last
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package gfc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+const testChunkSize uint32 = 64
+
+func TestStream(t *testing.T) {
+	key := make([]byte, aes256BitKeyFileLen)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("error filling random key bytes: %s", err.Error())
+	}
+
+	chunkSize := int(testChunkSize)
+	lens := []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3 * chunkSize, 3*chunkSize + 7}
+
+	for _, mode := range []AlgoMode{ModeAesGCM, ModeXChaCha20Poly1305, ModeChaCha20Poly1305} {
+		for _, l := range lens {
+			plaintext := make([]byte, l)
+			if _, err := rand.Read(plaintext); err != nil {
+				t.Fatalf("error filling random plaintext bytes: %s", err.Error())
+			}
+
+			ciphertext := encryptStream(t, mode, plaintext, key)
+
+			decrypted := decryptStream(t, ciphertext, key)
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Fatalf("%s: output does not match for length %d", mode, l)
+			}
+
+			// Buffer functions must also decrypt chunked output
+			decryptedBuf, err := decryptBuffer(mode, bytes.NewBuffer(ciphertext), key, nil)
+			if err != nil {
+				t.Fatalf("%s: error decrypting chunked output to buffer: %s", mode, err.Error())
+			}
+
+			decrypted, _ = io.ReadAll(decryptedBuf)
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Fatalf("%s: buffer output does not match for length %d", mode, l)
+			}
+		}
+	}
+}
+
+func TestStreamTampered(t *testing.T) {
+	key := make([]byte, aes256BitKeyFileLen)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("error filling random key bytes: %s", err.Error())
+	}
+
+	plaintext := bytes.Repeat([]byte("gfc"), 3*int(testChunkSize))
+	ciphertext := encryptStream(t, ModeAesGCM, plaintext, key)
+	other := encryptStream(t, ModeAesGCM, plaintext, key)
+
+	_, lenHeader, err := parseHeader(ciphertext)
+	if err != nil {
+		t.Fatalf("failed to parse header: %s", err.Error())
+	}
+
+	lenChunk := int(testChunkSize) + 16
+	chunk := func(b []byte, i int) []byte {
+		return b[lenHeader+i*lenChunk : lenHeader+(i+1)*lenChunk]
+	}
+
+	concat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	tests := map[string][]byte{
+		"truncated at chunk boundary": ciphertext[:lenHeader+2*lenChunk],
+		"truncated mid chunk":         ciphertext[:len(ciphertext)-1],
+		"header only":                 ciphertext[:lenHeader],
+		"reordered chunks":            concat(ciphertext[:lenHeader], chunk(ciphertext, 1), chunk(ciphertext, 0), ciphertext[lenHeader+2*lenChunk:]),
+		"swapped chunk":               concat(ciphertext[:lenHeader], chunk(other, 0), ciphertext[lenHeader+lenChunk:]),
+		"trailing garbage":            concat(ciphertext, []byte{0}),
+	}
+
+	for name, tampered := range tests {
+		r, err := NewDecryptReader(bytes.NewReader(tampered), key)
+		if err != nil {
+			t.Fatalf("%s: unexpected error creating decrypt reader: %s", name, err.Error())
+		}
+
+		_, err = io.ReadAll(r)
+		if !errors.Is(err, ErrOpenStream) && !errors.Is(err, ErrStreamTruncated) {
+			t.Fatalf("%s: expecting stream error, got %v", name, err)
+		}
+	}
+}
+
+func TestStreamFileSubkey(t *testing.T) {
+	key := make([]byte, aes256BitKeyFileLen)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("error filling random key bytes: %s", err.Error())
+	}
+
+	// Files encrypted with the same key must not share cipher key
+	var salts, subkeys [][]byte
+	for i := 0; i < 2; i++ {
+		hdr, _, subkey, err := newHeaderSymm(ModeAesGCM, lenNonceAESGCM256-lenStreamNonceSuffix, testChunkSize, key, newOptions(nil))
+		if err != nil {
+			t.Fatalf("failed to create header: %s", err.Error())
+		}
+
+		if len(hdr.FileSalt) != lenFileSalt {
+			t.Fatalf("unexpected file salt length %d", len(hdr.FileSalt))
+		}
+
+		if bytes.Equal(subkey, key) {
+			t.Fatal("cipher key is the same as keyfile key")
+		}
+
+		salts = append(salts, hdr.FileSalt)
+		subkeys = append(subkeys, subkey)
+	}
+
+	if bytes.Equal(salts[0], salts[1]) || bytes.Equal(subkeys[0], subkeys[1]) {
+		t.Fatal("files share file salt or cipher key")
+	}
+
+	plaintext := []byte("gfc")
+	if decrypted := decryptStream(t, encryptStream(t, ModeAesGCM, plaintext, key), key); !bytes.Equal(decrypted, plaintext) {
+		t.Fatal("output does not match")
+	}
+}
+
+func encryptStream(t *testing.T, mode AlgoMode, plaintext, key []byte) []byte {
+	t.Helper()
+
+	ciphertext := new(bytes.Buffer)
+	w, err := NewEncryptWriter(ciphertext, mode, key, WithChunkSize(testChunkSize))
+	if err != nil {
+		t.Fatalf("%s: error creating encrypt writer: %s", mode, err.Error())
+	}
+
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatalf("%s: error writing plaintext: %s", mode, err.Error())
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("%s: error closing encrypt writer: %s", mode, err.Error())
+	}
+
+	return ciphertext.Bytes()
+}
+
+func decryptStream(t *testing.T, ciphertext, key []byte) []byte {
+	t.Helper()
+
+	r, err := NewDecryptReader(bytes.NewReader(ciphertext), key)
+	if err != nil {
+		t.Fatalf("error creating decrypt reader: %s", err.Error())
+	}
+
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("error decrypting stream: %s", err.Error())
+	}
+
+	return plaintext
+}
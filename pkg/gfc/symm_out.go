@@ -1,72 +1,199 @@
 package gfc
 
 import (
-	"bytes"
-	"fmt"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
 
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/hkdf"
 )
 
-// formatOutputGfcSymm serializes the output for all symmetric key encryption by gfc
-func formatOutputGfcSymm(
-	ciphertext []byte,
-	nonce []byte,
-	salt []byte,
+const (
+	lenFileSalt    int = 32
+	infoFileSubkey     = "gfc per-file subkey"
+)
+
+// symmOut represents unmarshaled legacy gfc symmetric key encryption output
+type symmOut struct {
+	ciphertext []byte
+	nonce      []byte
+	key        []byte
+}
+
+// newHeaderSymm creates header for symmetric key encryption with mode,
+// and derives per-file cipher key from key. If key is nil, a passphrase is used.
+// chunkSize is only non-zero for chunked stream encryption (see stream.go).
+func newHeaderSymm(
+	mode AlgoMode,
+	nonceSize int,
+	chunkSize uint32,
+	key []byte,
+	o *options,
 ) (
-	Buffer,
+	*Header,
+	[]byte, // Serialized header
+	[]byte, // Key
 	error,
 ) {
-	lenNonce := len(nonce)
-	lenSalt := len(salt)
+	hdr := newHeader(mode, o)
+	hdr.ChunkSize = chunkSize
 
-	buf := bytes.NewBuffer(ciphertext)
-	n, err := buf.Write(nonce)
+	key, err := keyEncryptSymm(hdr, key, o)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to append nonce to symmetric encryption output")
+		return nil, nil, nil, err
 	}
 
-	if n != lenNonce {
-		return nil, fmt.Errorf("unexpected nonce bytes written - expecting %d, got %d", lenNonce, n)
+	hdr.FileSalt = make([]byte, lenFileSalt)
+	if _, err := rand.Read(hdr.FileSalt); err != nil {
+		return nil, nil, nil, errors.Wrapf(ErrRandom, "failed to read random file salt: %s", err)
 	}
 
-	n, err = buf.Write(salt)
+	key, err = fileSubkey(key, hdr.FileSalt)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to append salt to symmetric encryption output")
+		return nil, nil, nil, err
+	}
+
+	hdr.Nonce = make([]byte, nonceSize)
+	if _, err := rand.Read(hdr.Nonce); err != nil {
+		return nil, nil, nil, errors.Wrapf(ErrRandom, "failed to read random nonce: %s", err)
 	}
 
-	if n != lenSalt {
-		return nil, fmt.Errorf("unexpected salt bytes written - expecting %d, got %d", lenSalt, n)
+	aad, err := hdr.marshal()
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	return buf, nil
+	return hdr, aad, key, nil
 }
 
-// decodeOutputGfcSymm unmarshals gfc symmetric key encryption output into message length, ciphertext, key, and nonce
-func decodeOutputGfcSymm(
-	ciphertext Buffer,
+// fileSubkey derives per-file cipher key from key and salt with HKDF-SHA256.
+// Keyfile keys, and passphrase keys shared by KeyCache, are used for many files,
+// so without it the short random nonce prefixes of chunked output (see stream.go)
+// could collide across files encrypted with the same cipher key.
+func fileSubkey(key, salt []byte) ([]byte, error) {
+	subkey := make([]byte, aes256BitKeyFileLen)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, salt, []byte(infoFileSubkey)), subkey); err != nil {
+		return nil, errors.Wrap(err, "HKDF failed")
+	}
+
+	return subkey, nil
+}
+
+// keyEncryptSymm returns key for new encryption, from which the cipher key is derived
+// with fileSubkey, and records the KDF used in hdr. If key is nil, the key is derived
+// from a passphrase with the KDF from o.
+func keyEncryptSymm(hdr *Header, key []byte, o *options) ([]byte, error) {
+	if key != nil && o.passphrase != nil {
+		return nil, errors.Wrap(ErrKeySource, "both keyfile and passphrase given")
+	}
+
+	if key != nil {
+		if keyLen := len(key); keyLen != aes256BitKeyFileLen {
+			return nil, errors.Wrapf(ErrInvalidaes256BitKeyFileLen, "keyfile length is %d", keyLen)
+		}
+
+		hdr.KDF = KDFParams{KDF: KDFNone}
+		return key, nil
+	}
+
+	if o.kdf.KDF == KDFNone {
+		return nil, errors.Wrap(ErrKeySource, "missing keyfile or passphrase KDF")
+	}
+
+	// Bad parameters are caught before prompting for passphrase
+	if err := o.kdf.Validate(); err != nil {
+		return nil, err
+	}
+
+	hdr.KDF = o.kdf
+
+	salt, key, err := o.keys.encryptMasterKey(o.kdf, o.passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	hdr.Salt = salt
+
+	return key, nil
+}
+
+// keyDecryptSymm returns cipher key for decrypting ciphertext with hdr.
+// Output without header FileSalt was encrypted with key itself.
+func keyDecryptSymm(hdr *Header, key []byte, o *options) ([]byte, error) {
+	key, err := keySymm(hdr, key, o)
+	if err != nil || len(hdr.FileSalt) == 0 {
+		return key, err
+	}
+
+	return fileSubkey(key, hdr.FileSalt)
+}
+
+// keySymm returns keyfile key or passphrase key for decrypting ciphertext with hdr
+func keySymm(hdr *Header, key []byte, o *options) ([]byte, error) {
+	if key != nil && o.passphrase != nil {
+		return nil, errors.Wrap(ErrKeySource, "both keyfile and passphrase given")
+	}
+
+	if hdr.KDF.KDF == KDFNone {
+		if key == nil {
+			return nil, errors.Wrap(ErrKeySource, "ciphertext was encrypted with a keyfile")
+		}
+
+		if keyLen := len(key); keyLen != aes256BitKeyFileLen {
+			return nil, errors.Wrapf(ErrInvalidaes256BitKeyFileLen, "keyfile length is %d", keyLen)
+		}
+
+		return key, nil
+	}
+
+	if key != nil {
+		return nil, errors.Wrapf(ErrKeySource, "ciphertext was encrypted with a passphrase (%s)", hdr.KDF.KDF)
+	}
+
+	return o.keys.masterKey(hdr.KDF, hdr.Salt, o.passphrase, false)
+}
+
+// decodeLegacyOutputGfcSymm unmarshals output written by gfc before header was introduced:
+//
+//	<Ciphertext> <Cipher Nonce> <PBKDF2 Salt>
+//
+// The ciphertext of mode must be at least tagSize bytes long.
+func decodeLegacyOutputGfcSymm(
+	ciphertext []byte,
 	key []byte,
+	mode AlgoMode,
 	nonceSize int,
+	tagSize int,
+	o *options,
 ) (
-	int, // lenMsg or nonceStart
-	[]byte, // Ciphertext
-	[]byte, // Key
-	[]byte, // Nonce
+	*symmOut,
 	error,
 ) {
-	ciphertextBytes := ciphertext.Bytes()
-	lenGfcCiphertext := ciphertext.Len()
+	if minLen := tagSize + nonceSize + lenPBKDF2Salt; len(ciphertext) < minLen {
+		return nil, errors.Wrapf(ErrTruncated, "input too short for %s: need at least %d bytes, got %d", mode, minLen, len(ciphertext))
+	}
 
-	saltStart := lenGfcCiphertext - lenPBKDF2Salt
-	salt := ciphertextBytes[saltStart:]
+	saltStart := len(ciphertext) - lenPBKDF2Salt
+	salt := ciphertext[saltStart:]
 
-	key, _, err := keySaltPBKDF2(key, salt)
-	if err != nil {
-		return 0, nil, nil, nil, errors.Wrap(err, ErrPBKDF2KeySalt.Error())
+	if key != nil {
+		if keyLen := len(key); keyLen != aes256BitKeyFileLen {
+			return nil, errors.Wrapf(ErrInvalidaes256BitKeyFileLen, "keyfile length is %d", keyLen)
+		}
+	} else {
+		// Older gfc always derived key with PBKDF2-SHA256 using default iterations
+		var err error
+		if key, err = o.keys.masterKey(DefaultKDFParams(KDFPBKDF2), salt, o.passphrase, false); err != nil {
+			return nil, err
+		}
 	}
 
 	nonceStart := saltStart - nonceSize
-	nonce := ciphertextBytes[nonceStart:saltStart]
-	ciphertextBytes = ciphertextBytes[:nonceStart]
 
-	return nonceStart, ciphertextBytes, key, nonce, nil
+	return &symmOut{
+		ciphertext: ciphertext[:nonceStart],
+		nonce:      ciphertext[nonceStart:saltStart],
+		key:        key,
+	}, nil
 }
@@ -0,0 +1,74 @@
+package gfc
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestX25519(t *testing.T) {
+	pubs := make([]string, 2)
+	pris := make([]string, 2)
+
+	for i := range pubs {
+		pub, pri, err := GenerateX25519()
+		if err != nil {
+			t.Fatalf("failed to generate X25519 keypair: %s", err.Error())
+		}
+
+		derived, err := PublicKeyX25519(pri)
+		if err != nil || derived != pub {
+			t.Fatalf("unexpected public key - expecting %s, got %s (%v)", pub, derived, err)
+		}
+
+		pubs[i], pris[i] = pub, pri
+	}
+
+	plaintext := bytes.Repeat([]byte("this is my plaintext"), 100)
+	recipients := []byte("# comment\n" + strings.Join(pubs, "\n") + "\n")
+
+	ciphertext, err := EncryptX25519(bytes.NewBuffer(plaintext), recipients)
+	if err != nil {
+		t.Fatalf("error encrypting: %s", err.Error())
+	}
+
+	hdr, err := ParseHeader(ciphertext.Bytes())
+	if err != nil {
+		t.Fatalf("failed to parse header: %s", err.Error())
+	}
+
+	if hdr.Payload != ModeXChaCha20Poly1305 || len(hdr.Stanzas) != len(pubs) {
+		t.Fatalf("unexpected header %+v", hdr)
+	}
+
+	// Any recipient can decrypt
+	for _, pri := range pris {
+		decrypted, err := DecryptX25519(bytes.NewBuffer(ciphertext.Bytes()), []byte(pri))
+		if err != nil {
+			t.Fatalf("error decrypting: %s", err.Error())
+		}
+
+		if !bytes.Equal(decrypted.Bytes(), plaintext) {
+			t.Fatal("output does not match")
+		}
+	}
+
+	_, other, _ := GenerateX25519()
+	if _, err := DecryptX25519(bytes.NewBuffer(ciphertext.Bytes()), []byte(other)); !errors.Is(err, ErrNoRecipient) {
+		t.Fatalf("expecting ErrNoRecipient, got %v", err)
+	}
+
+	bad := map[string]string{
+		"private key as recipient": pris[0],
+		"bad base32":               PrefixX25519PublicKey + "!!!",
+		"short key":                PrefixX25519PublicKey + "AAAA",
+		"empty":                    "# only comment",
+	}
+
+	for name, recipients := range bad {
+		if _, err := EncryptX25519(bytes.NewBuffer(plaintext), []byte(recipients)); !errors.Is(err, ErrParseX25519) {
+			t.Fatalf("%s: expecting ErrParseX25519, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package gfc
+
+// This file provides Z85 (ZeroMQ base-85), which is ascii85 with an alphabet
+// safe for source code and JSON strings (no quotes or backslash).
+// Z85 is implemented by translating ascii85 from encoding/ascii85 character by character,
+// and so, like ascii85, input length does not have to be a multiple of 4:
+// a partial last group is encoded with fewer characters. Output of input with
+// length multiple of 4 is standard Z85.
+
+import (
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const alphabetZ85 = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ.-:+=^!/*?&<>()[]{}@%$#"
+
+const (
+	ascii85First = '!' // ascii85 digit 0
+	ascii85Zero  = 'z' // ascii85 shorthand for a group of 4 zero bytes
+)
+
+// z85Writer translates ascii85 written to it to Z85, and writes it to w
+type z85Writer struct {
+	w io.Writer
+}
+
+func (z *z85Writer) Write(p []byte) (int, error) {
+	out := make([]byte, 0, len(p))
+	for _, c := range p {
+		if c == ascii85Zero {
+			out = append(out, strings.Repeat(alphabetZ85[:1], 5)...)
+			continue
+		}
+
+		out = append(out, alphabetZ85[c-ascii85First])
+	}
+
+	if _, err := z.w.Write(out); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// z85Reader translates Z85 read from r to ascii85. Whitespace is kept,
+// since the ascii85 decoder ignores it.
+type z85Reader struct {
+	r io.Reader
+}
+
+func (z *z85Reader) Read(p []byte) (int, error) {
+	n, err := z.r.Read(p)
+	for i, c := range p[:n] {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+
+		digit := strings.IndexByte(alphabetZ85, c)
+		if digit < 0 {
+			return i, errors.Wrapf(ErrEncoding, "bad Z85 character %q", c)
+		}
+
+		p[i] = byte(ascii85First + digit)
+	}
+
+	return n, err
+}
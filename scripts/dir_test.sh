@@ -0,0 +1,23 @@
+#!/usr/bin/env sh
+
+printf "%s\n" "You should run this script from the project root";
+
+WORK_DIR="tmptest"; # Base directory for testing (will be ignored by git)
+SOURCE_DIR="assets/files"; # Test files
+KEY="assets/files/aes.key";
+GFC_OUT="${WORK_DIR}/files.bin"; # Outfile name
+OUTDIR="${WORK_DIR}/outbin.d";
+
+mkdir -p "${WORK_DIR}";
+
+ENC_CMD="go run ./cmd/gfc aes -k ${KEY} -c -i ${SOURCE_DIR} -o ${GFC_OUT}";
+DEC_CMD="go run ./cmd/gfc aes -k ${KEY} -c -d -i ${GFC_OUT} -o ${OUTDIR}";
+DIFF_CMD="diff -r ${OUTDIR} ${SOURCE_DIR}";
+printf "ENC_CMD: '%s'\nDEC_CMD: '%s'\nDIFF_CMD: '%s'\n" "${ENC_CMD}" "${DEC_CMD}" "${DIFF_CMD}";
+
+sh -c "${ENC_CMD}"\
+&& sh -c "${DEC_CMD}"\
+&& sh -c "${DIFF_CMD}"\
+&& printf "\n\ndir_test.sh: Ok\n";
+
+rm -r "${WORK_DIR}";
@@ -46,7 +46,9 @@ ENCODING_ENUMS["Base64"]="--encoding b64";
 
 typeset -A COMPRESSION_ENUMS
 COMPRESSION_ENUMS["NoCompress"]="";
-COMPRESSION_ENUMS["Compress"]="--compress";
+COMPRESSION_ENUMS["Compress"]="-c";
+COMPRESSION_ENUMS["Gzip"]="--compress gzip:9";
+COMPRESSION_ENUMS["S2"]="--compress s2";
 
 # file_test() runs 1 test with 1 output file. It accept 6 arguments for the test.
 # it is used to print test info to screen as well as running the actual test,
@@ -1,7 +1,7 @@
 #!/bin/sh
 
 function copyfile() {
-    cp -a gfc scripts/rgfc.sh ~/bin/.;
+    cp -a gfc ~/bin/.;
 }
 
 test  -f gfc \